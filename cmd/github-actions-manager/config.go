@@ -7,6 +7,7 @@ import (
 
 	"github.com/oursky/github-actions-manager/pkg/api"
 	"github.com/oursky/github-actions-manager/pkg/dashboard"
+	"github.com/oursky/github-actions-manager/pkg/github"
 	"github.com/oursky/github-actions-manager/pkg/github/auth"
 	"github.com/oursky/github-actions-manager/pkg/github/jobs"
 	"github.com/oursky/github-actions-manager/pkg/github/runners"
@@ -17,6 +18,11 @@ import (
 	"github.com/spf13/viper"
 )
 
+const (
+	defaultTargetID     = "default"
+	defaultCredentialID = "default"
+)
+
 type Config struct {
 	GitHub    GitHubConfig
 	Dashboard dashboard.Config
@@ -26,15 +32,53 @@ type Config struct {
 }
 
 type GitHubConfig struct {
-	TargetURL   string `validate:"required,url"`
+	TargetURL   string         `validate:"required_without=Targets,omitempty,url"`
+	Targets     []TargetConfig `validate:"unique=ID,dive"`
+	APIURL      *string        `validate:"omitempty,url"`
+	UploadURL   *string        `validate:"omitempty,url"`
 	RPS         *float64
 	Brust       *int
 	HTTPTimeout *time.Duration
 	Auth        auth.Config
+	AuthPool    []auth.Config `validate:"dive"`
+	Cache       github.CacheConfig
 	Runners     runners.Config
 	Jobs        jobs.Config
 }
 
+// GetCredentials returns configured credentials by ID; Auth is the primary
+// credential.
+func (c *GitHubConfig) GetCredentials() []CredentialConfig {
+	credentials := []CredentialConfig{{ID: defaultCredentialID, Auth: &c.Auth}}
+	for i := range c.AuthPool {
+		credentials = append(credentials, CredentialConfig{
+			ID:   fmt.Sprintf("pool-%d", i+1),
+			Auth: &c.AuthPool[i],
+		})
+	}
+	return credentials
+}
+
+type CredentialConfig struct {
+	ID   string
+	Auth *auth.Config
+}
+
+type TargetConfig struct {
+	ID  string `validate:"required,excludesall=/"`
+	URL string `validate:"required,url"`
+}
+
+// GetTargets returns configured targets; TargetURL is treated as the target
+// with ID "default".
+func (c *GitHubConfig) GetTargets() []TargetConfig {
+	var targets []TargetConfig
+	if c.TargetURL != "" {
+		targets = append(targets, TargetConfig{ID: defaultTargetID, URL: c.TargetURL})
+	}
+	return append(targets, c.Targets...)
+}
+
 type StoreConfig struct {
 	KubeNamespace string `validate:"required"`
 }
@@ -64,5 +108,13 @@ func NewConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
+	targetIDs := make(map[string]struct{})
+	for _, t := range config.GitHub.GetTargets() {
+		if _, ok := targetIDs[t.ID]; ok {
+			return nil, fmt.Errorf("invalid config: duplicated target ID: %s", t.ID)
+		}
+		targetIDs[t.ID] = struct{}{}
+	}
+
 	return &config, nil
 }
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 
 	"github.com/oursky/github-actions-manager/pkg/cmd"
+	"github.com/oursky/github-actions-manager/pkg/github/jobs"
 
 	"go.uber.org/zap"
 )
@@ -11,6 +13,7 @@ import (
 func main() {
 	configPath := flag.String("config", "", "path to config file")
 	loglevel := zap.LevelFlag("loglevel", zap.InfoLevel, "log level")
+	replayPath := flag.String("replay", "", "path to webhook journal to replay to running manager")
 	flag.Parse()
 
 	cfg := zap.NewProductionConfig()
@@ -23,6 +26,22 @@ func main() {
 		logger.Fatal("failed to load config", zap.Error(err))
 	}
 
+	if *replayPath != "" {
+		jobsConfig := &config.GitHub.Jobs
+		url := "http://" + jobsConfig.GetWebhookServerAddr()
+		result, err := jobs.ReplayJournal(context.Background(), *replayPath, url, jobsConfig.WebhookSecret)
+		fields := []zap.Field{
+			zap.Int("replayed", result.Replayed),
+			zap.Int("duplicated", result.Duplicated),
+			zap.Int("skipped", result.Skipped),
+		}
+		if err != nil {
+			logger.Fatal("failed to replay webhooks", append(fields, zap.Error(err))...)
+		}
+		logger.Info("replayed webhooks", fields...)
+		return
+	}
+
 	modules, err := initModules(logger, config)
 	if err != nil {
 		logger.Fatal("failed to init", zap.Error(err))
@@ -17,7 +17,6 @@ import (
 	"github.com/oursky/github-actions-manager/pkg/utils/defaults"
 	"github.com/oursky/github-actions-manager/pkg/utils/ratelimit"
 
-	gh "github.com/google/go-github/v45/github"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"go.uber.org/zap"
@@ -29,58 +28,110 @@ func initModules(logger *zap.Logger, config *Config) ([]cmd.Module, error) {
 	registry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
 	registry.MustRegister(collectors.NewGoCollector())
 
-	transport, err := auth.NewTransport(
-		&config.GitHub.Auth,
-		http.DefaultTransport,
-	)
+	var modules []cmd.Module
+
+	kv, err := kv.NewStore(logger, &config.Store)
 	if err != nil {
-		return nil, fmt.Errorf("cannot setup GitHub client: %w", err)
+		return nil, fmt.Errorf("cannot setup store: %w", err)
+	}
+	modules = append(modules, kv)
+
+	cache, err := github.NewCache(logger, &config.GitHub.Cache, kv)
+	if err != nil {
+		return nil, fmt.Errorf("cannot setup GitHub cache: %w", err)
 	}
-	transport = github.NewCachedTransport(
-		ratelimit.NewTransport(
-			transport,
+	modules = append(modules, cache)
+
+	apiURL := defaults.Value(config.GitHub.APIURL, "")
+	uploadURL := defaults.Value(config.GitHub.UploadURL, "")
+
+	apiMetrics := github.NewAPIMetrics(registry)
+
+	var credentials []*ratelimit.Transport
+	for _, c := range config.GitHub.GetCredentials() {
+		credential := c.ID
+		authTransport, err := auth.NewTransport(
+			logger.With(zap.String("credential", credential)),
+			c.Auth,
+			apiURL,
+			http.DefaultTransport,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("cannot setup GitHub credential %s: %w", credential, err)
+		}
+		authTransport.OnReload = func(err error) {
+			apiMetrics.ObserveCredentialReload(credential, err)
+		}
+		modules = append(modules, authTransport)
+
+		limiter := ratelimit.NewTransport(
+			apiMetrics.CredentialTransport(authTransport, credential),
 			rate.Limit(defaults.Value(config.GitHub.RPS, 1)),
 			defaults.Value(config.GitHub.Brust, 60),
-		),
-	)
+		)
+		limiter.OnWait = apiMetrics.ObserveLimiterWait
+		credentials = append(credentials, limiter)
+	}
+	pool := github.NewCredentialPool(credentials)
+	transport := apiMetrics.Transport(github.NewCachedTransport(pool, cache))
 
 	client := &http.Client{
 		Transport: transport,
 		Timeout:   defaults.Value(config.GitHub.HTTPTimeout, 10*time.Second),
 	}
-
-	target, err := github.NewTarget(client, config.GitHub.TargetURL)
+	ghClient, err := github.NewClient(client, apiURL, uploadURL)
 	if err != nil {
-		return nil, fmt.Errorf("cannot setup GitHub target: %w", err)
-	}
-
-	var modules []cmd.Module
-
-	kv, err := kv.NewStore(logger, &config.Store)
-	if err != nil {
-		return nil, fmt.Errorf("cannot setup store: %w", err)
+		return nil, fmt.Errorf("cannot setup GitHub client: %w", err)
 	}
-	modules = append(modules, kv)
-
-	runners := runners.NewSynchronizer(logger, &config.GitHub.Runners, target, registry)
-	modules = append(modules, runners)
 
-	jobs, err := jobs.NewSynchronizer(logger, &config.GitHub.Jobs, client, kv, registry)
+	jobs, err := jobs.NewSynchronizer(logger, &config.GitHub.Jobs, ghClient, kv, registry)
 	if err != nil {
 		return nil, fmt.Errorf("cannot setup job sync: %w", err)
 	}
+	jobs.SetQuota(pool)
+
+	var apiTargets []api.Target
+	var runnerStates []dashboard.RunnersState
+	for _, t := range config.GitHub.GetTargets() {
+		target, err := github.NewTarget(ghClient, t.URL)
+		if err != nil {
+			return nil, fmt.Errorf("cannot setup GitHub target %s: %w", t.ID, err)
+		}
+
+		// Without configured repositories, repository targets and all
+		// repositories of organization targets are polled.
+		if p := config.GitHub.Jobs.Polling; p != nil && len(p.Repositories) == 0 && len(p.Organizations) == 0 {
+			switch target := target.(type) {
+			case *github.TargetRepository:
+				jobs.AddPolledRepository(target.Owner, target.Name)
+			case *github.TargetOrganization:
+				jobs.AddPolledOrganization(target.Name)
+			}
+		}
+
+		runnerSync := runners.NewSynchronizer(logger, &config.GitHub.Runners, t.ID, target, registry)
+		modules = append(modules, runnerSync)
+		jobs.AddObserver(runnerSync)
+
+		reaper := runners.NewReaper(logger, &config.GitHub.Runners.Reaper, runnerSync, kv, registry)
+		modules = append(modules, reaper)
+
+		apiTargets = append(apiTargets, api.Target{ID: t.ID, Target: target, Runners: runnerSync})
+		runnerStates = append(runnerStates, runnerSync)
+	}
+
 	modules = append(modules, jobs)
 
 	slackApp := slack.NewApp(logger, &config.Slack, kv)
 	modules = append(modules, slackApp)
 
-	notifier := slack.NewNotifier(logger, slackApp, gh.NewClient(client), jobs)
+	notifier := slack.NewNotifier(logger, slackApp, ghClient, jobs)
 	modules = append(modules, notifier)
 
-	dashboard := dashboard.NewServer(logger, &config.Dashboard, runners, jobs)
+	dashboard := dashboard.NewServer(logger, &config.Dashboard, runnerStates, jobs)
 	modules = append(modules, dashboard)
 
-	api := api.NewServer(logger, &config.API, runners, target, registry)
+	api := api.NewServer(logger, &config.API, apiTargets, registry)
 	modules = append(modules, api)
 
 	return modules, nil
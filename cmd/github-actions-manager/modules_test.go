@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/github/auth"
+	"github.com/oursky/github-actions-manager/pkg/github/githubtest"
+	"github.com/oursky/github-actions-manager/pkg/kv"
+
+	"github.com/google/go-github/v45/github"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestModules(t *testing.T) {
+	Convey("Given a manager connected to a fake GitHub", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := githubtest.NewServer()
+		defer server.Close()
+		server.AddRunner("orgs/acme", &github.Runner{Name: github.String("runner-1")})
+
+		apiURL := server.APIURL()
+		apiAddr := githubtest.FreeAddr()
+		webhookAddr := githubtest.FreeAddr()
+		syncInterval := 10 * time.Millisecond
+
+		config := &Config{}
+		config.GitHub.TargetURL = server.URL + "/acme"
+		config.GitHub.APIURL = &apiURL
+		config.GitHub.UploadURL = &apiURL
+		config.GitHub.Auth = auth.Config{Type: auth.TypeToken, Token: "token"}
+		config.GitHub.Runners.SyncInterval = &syncInterval
+		config.GitHub.Jobs.WebhookServerAddr = &webhookAddr
+		config.GitHub.Jobs.WebhookSecret = "secret"
+		config.Store.Type = kv.TypeInMemory
+		config.Slack.Disabled = true
+		config.Dashboard.Disabled = true
+		config.API.Addr = &apiAddr
+		config.API.AuthKeys = []string{"key"}
+
+		modules, err := initModules(zap.NewNop(), config)
+		So(err, ShouldBeNil)
+
+		g, ctx := errgroup.WithContext(ctx)
+		for _, m := range modules {
+			So(m.Start(ctx, g), ShouldBeNil)
+		}
+
+		call := func(method string, path string, body any, result any) (int, error) {
+			data, _ := json.Marshal(body)
+			r, err := http.NewRequestWithContext(ctx, method, "http://"+apiAddr+path, bytes.NewReader(data))
+			if err != nil {
+				return 0, err
+			}
+			r.Header.Set("Authorization", "Bearer key")
+
+			resp, err := http.DefaultClient.Do(r)
+			if err != nil {
+				return 0, err
+			}
+			defer resp.Body.Close()
+			if result != nil {
+				json.NewDecoder(resp.Body).Decode(result)
+			}
+			return resp.StatusCode, nil
+		}
+
+		Convey("Runners are served by manager API", func() {
+			var resp struct {
+				Runners []struct {
+					Name string `json:"name"`
+				} `json:"runners"`
+			}
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				status, err := call("GET", "/api/v1/targets/default/runners", nil, &resp)
+				return err == nil && status == 200 && len(resp.Runners) == 1
+			})
+			So(ok, ShouldBeTrue)
+			So(resp.Runners[0].Name, ShouldEqual, "runner-1")
+		})
+
+		Convey("JIT configs register runners on the target", func() {
+			var resp struct {
+				RunnerID         int64  `json:"runnerID"`
+				EncodedJITConfig string `json:"encodedJITConfig"`
+			}
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				status, err := call("POST", "/api/v1/jitconfig", map[string]any{
+					"name":   "runner-2",
+					"labels": []string{"linux"},
+				}, &resp)
+				return err == nil && status == 200
+			})
+			So(ok, ShouldBeTrue)
+			So(resp.EncodedJITConfig, ShouldNotBeEmpty)
+
+			runners := server.Runners("orgs/acme")
+			So(runners, ShouldHaveLength, 2)
+			So(runners[1].GetID(), ShouldEqual, resp.RunnerID)
+			So(runners[1].GetName(), ShouldEqual, "runner-2")
+		})
+	})
+}
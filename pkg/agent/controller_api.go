@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/url"
+	"runtime"
 	"time"
 
 	"github.com/oursky/github-actions-manager/pkg/controller"
@@ -38,12 +39,18 @@ func (c *controllerAPI) doJSON(r *http.Request, result any) error {
 	return json.NewDecoder(resp.Body).Decode(&result)
 }
 
-func (c *controllerAPI) RegisterAgent(ctx context.Context, hostName string) (*controller.AgentResponse, error) {
+func (c *controllerAPI) RegisterAgent(ctx context.Context, hostName string, workDir string) (*controller.AgentResponse, error) {
+	form := url.Values{
+		"hostName": []string{hostName},
+		"workDir":  []string{workDir},
+		"os":       []string{runtime.GOOS},
+		"arch":     []string{runtime.GOARCH},
+	}
 	r, err := c.provider.NewControllerRequest(
 		ctx,
 		http.MethodPost,
 		"api/v1/agent",
-		bytes.NewBufferString(url.Values{"hostName": []string{hostName}}.Encode()),
+		bytes.NewBufferString(form.Encode()),
 	)
 	if err != nil {
 		return nil, err
@@ -52,7 +52,7 @@ func (m *executer) execute(ctx context.Context) {
 	m.logger.Info("registering agent", zap.String("hostName", hostName))
 	var resp *controller.AgentResponse
 	for {
-		resp, err = m.controllerAPI.RegisterAgent(ctx, hostName)
+		resp, err = m.controllerAPI.RegisterAgent(ctx, hostName, m.config.WorkDir)
 		if err == nil {
 			break
 		}
@@ -68,22 +68,15 @@ func (m *executer) execute(ctx context.Context) {
 
 	m.provider.OnAgentRegistered(resp.Agent)
 
-	m.logger.Info("configuring runner",
+	// Runner is registered with JIT config; ensure it is cleaned up on termination.
+	m.agentCh <- resp.Agent
+
+	m.logger.Info("starting runner",
 		zap.String("target", resp.TargetURL),
 		zap.String("group", resp.Group),
 		zap.Strings("labels", resp.Labels),
 	)
-	err = m.configure(context.Background(), resp)
-	if err != nil {
-		m.logger.Error("failed to configure runner", zap.Error(err))
-		return
-	}
-
-	// Ensure runner is configured before termination.
-	m.agentCh <- resp.Agent
-
-	m.logger.Info("starting runner")
-	err = m.start(ctx)
+	err = m.start(ctx, resp)
 	if err != nil {
 		m.logger.Error("failed to start runner", zap.Error(err))
 		return
@@ -102,46 +95,18 @@ func (m *executer) setupRunnerCmd(cmd *exec.Cmd) {
 	}
 }
 
-func (m *executer) configure(ctx context.Context, resp *controller.AgentResponse) error {
-	retry := 10
-	for {
-		args := []string{
-			"--unattended",
-			"--replace",
-			"--ephemeral",
-			"--name", resp.Agent.RunnerName,
-			"--url", resp.TargetURL,
-			"--token", resp.Token,
-			"--work", m.config.WorkDir,
-		}
-		if resp.Group != "" {
-			args = append(args, "--runnergroup", resp.Group)
-		}
-		if len(resp.Labels) > 0 {
-			args = append(args, "--labels", strings.Join(resp.Labels, ","))
-		}
-		if resp.DisableUpdate != nil && *resp.DisableUpdate {
-			args = append(args, "--disableupdate")
-		}
-		cmd := exec.CommandContext(ctx, m.config.GetConfigureScript(), args...)
-		m.setupRunnerCmd(cmd)
-
-		m.logger.Debug("starting config.sh", zap.String("cmd", cmd.String()))
-		err := cmd.Run()
-		if err != nil && retry > 0 {
-			m.logger.Warn("failed to configure runner", zap.Error(err))
-			retry--
-			continue
-		}
-		return err
-	}
+// runnerCmd returns command starting runner with JIT config. Options of
+// config.sh (e.g. --disableupdate) are not accepted by run.sh.
+func (m *executer) runnerCmd(resp *controller.AgentResponse) *exec.Cmd {
+	cmd := exec.Command(m.config.GetRunScript(), "--jitconfig", resp.JITConfig)
+	m.setupRunnerCmd(cmd)
+	return cmd
 }
 
-func (m *executer) start(ctx context.Context) error {
-	cmd := exec.Command(m.config.GetRunScript())
-	m.setupRunnerCmd(cmd)
+func (m *executer) start(ctx context.Context, resp *controller.AgentResponse) error {
+	cmd := m.runnerCmd(resp)
 
-	m.logger.Debug("starting run.sh", zap.String("cmd", cmd.String()))
+	m.logger.Debug("starting run.sh")
 	if err := cmd.Start(); err != nil {
 		return err
 	}
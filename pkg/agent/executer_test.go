@@ -0,0 +1,20 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/oursky/github-actions-manager/pkg/controller"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func TestExecuter(t *testing.T) {
+	Convey("Runner is started with JIT config only", t, func() {
+		m := newExecuter(zap.NewNop(), &Config{RunnerDir: "/runner", WorkDir: "/work"}, nil, nil, nil)
+		cmd := m.runnerCmd(&controller.AgentResponse{JITConfig: "config"})
+
+		So(cmd.Args, ShouldResemble, []string{"./run.sh", "--jitconfig", "config"})
+		So(cmd.Dir, ShouldEqual, "/runner")
+	})
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/oursky/github-actions-manager/pkg/github/runners"
 	"github.com/oursky/github-actions-manager/pkg/utils/channels"
 	"github.com/oursky/github-actions-manager/pkg/utils/httputil"
+	"github.com/oursky/github-actions-manager/pkg/utils/ratelimit"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -21,18 +22,33 @@ import (
 
 type RunnersState interface {
 	State() *channels.Broadcaster[*runners.State]
+	Events() *channels.Broadcaster[*runners.Events]
+	Utilization(window time.Duration) *runners.Utilization
 }
 
-type Server struct {
-	logger   *zap.Logger
-	enabled  bool
-	server   *http.Server
-	runners  RunnersState
+type Target struct {
+	ID      string
+	Target  github.Target
+	Runners RunnersState
+}
+
+type target struct {
+	id       string
 	target   github.Target
+	runners  RunnersState
 	regToken *github.RegistrationTokenStore
 }
 
-func NewServer(logger *zap.Logger, config *Config, runners RunnersState, target github.Target, gatherer prometheus.Gatherer) *Server {
+type Server struct {
+	logger        *zap.Logger
+	enabled       bool
+	server        *http.Server
+	targets       map[string]*target
+	targetIDs     []string
+	defaultTarget *target
+}
+
+func NewServer(logger *zap.Logger, config *Config, targets []Target, gatherer prometheus.Gatherer) *Server {
 	if config.Disabled {
 		return &Server{enabled: false}
 	}
@@ -50,9 +66,21 @@ func NewServer(logger *zap.Logger, config *Config, runners RunnersState, target
 			Handler:      r,
 			ErrorLog:     zap.NewStdLog(logger),
 		},
-		runners:  runners,
-		target:   target,
-		regToken: github.NewRegistrationTokenStore(logger, target),
+		targets: make(map[string]*target),
+	}
+
+	for _, t := range targets {
+		tgt := &target{
+			id:       t.ID,
+			target:   t.Target,
+			runners:  t.Runners,
+			regToken: github.NewRegistrationTokenStore(logger.With(zap.String("target", t.ID)), t.Target),
+		}
+		server.targets[t.ID] = tgt
+		server.targetIDs = append(server.targetIDs, t.ID)
+		if server.defaultTarget == nil {
+			server.defaultTarget = tgt
+		}
 	}
 
 	r.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
@@ -60,13 +88,43 @@ func NewServer(logger *zap.Logger, config *Config, runners RunnersState, target
 	}))
 	apiR := r.PathPrefix("/api/v1").Subrouter()
 	apiR.Use(httputil.NewKeyAuthMiddleware(config.AuthKeys).Middleware)
-	apiR.HandleFunc("/token", server.apiToken).Methods("GET")
-	apiR.HandleFunc("/runners", server.apiRunnersGet).Methods("GET")
-	apiR.HandleFunc("/runners/{id}", server.apiRunnerDelete).Methods("DELETE")
+	apiR.Use(priorityMiddleware)
+	apiR.HandleFunc("/targets", server.apiTargetsGet).Methods("GET")
+
+	// Routes without target ID refer to the first configured target.
+	server.handleTargetRoutes(apiR)
+	server.handleTargetRoutes(apiR.PathPrefix("/targets/{target}").Subrouter())
 
 	return server
 }
 
+// priorityMiddleware lets GitHub API calls on behalf of controllers go ahead
+// of background synchronization.
+func priorityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := ratelimit.WithPriority(r.Context(), ratelimit.PriorityHigh)
+		ctx = github.WithModule(ctx, "api")
+		next.ServeHTTP(rw, r.WithContext(ctx))
+	})
+}
+
+func (s *Server) handleTargetRoutes(r *mux.Router) {
+	r.HandleFunc("/token", s.apiToken).Methods("GET")
+	r.HandleFunc("/jitconfig", s.apiJITConfig).Methods("POST")
+	r.HandleFunc("/utilization", s.apiUtilizationGet).Methods("GET")
+	r.HandleFunc("/runners", s.apiRunnersGet).Methods("GET")
+	r.HandleFunc("/runners/events", s.apiRunnerEventsGet).Methods("GET")
+	r.HandleFunc("/runners/{id}", s.apiRunnerDelete).Methods("DELETE")
+	r.HandleFunc("/runners/{id}/labels", s.apiRunnerLabelsGet).Methods("GET")
+	r.HandleFunc("/runners/{id}/labels", s.apiRunnerLabelsUpdate).Methods("POST", "PUT")
+	r.HandleFunc("/runners/{id}/labels/{label}", s.apiRunnerLabelDelete).Methods("DELETE")
+	r.HandleFunc("/runner-groups", s.apiRunnerGroupsGet).Methods("GET")
+	r.HandleFunc("/runner-groups", s.apiRunnerGroupPost).Methods("POST")
+	r.HandleFunc("/runner-groups/{id}", s.apiRunnerGroupPatch).Methods("PATCH")
+	r.HandleFunc("/runner-groups/{id}/repositories", s.apiRunnerGroupRepositoriesGet).Methods("GET")
+	r.HandleFunc("/runner-groups/{id}/repositories", s.apiRunnerGroupRepositoriesPut).Methods("PUT")
+}
+
 func (s *Server) Start(ctx context.Context, g *errgroup.Group) error {
 	if !s.enabled {
 		return nil
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/oursky/github-actions-manager/pkg/utils/httputil"
+
+	"go.uber.org/zap"
+)
+
+type jitConfigRequest struct {
+	Name       string   `json:"name"`
+	Group      string   `json:"group"`
+	Labels     []string `json:"labels"`
+	WorkFolder string   `json:"workFolder"`
+}
+
+type jitConfigResponse struct {
+	RunnerID         int64  `json:"runnerID"`
+	EncodedJITConfig string `json:"encodedJITConfig"`
+	URL              string `json:"url"`
+}
+
+func (s *Server) apiJITConfig(rw http.ResponseWriter, r *http.Request) {
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	var req jitConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		http.Error(rw, "empty runner name", http.StatusBadRequest)
+		return
+	}
+
+	config, err := target.target.GenerateJITConfig(r.Context(), req.Name, req.Group, req.Labels, req.WorkFolder)
+	if err != nil {
+		s.logger.Warn("failed to generate JIT config",
+			zap.Error(err),
+			zap.String("target", target.id),
+			zap.String("name", req.Name),
+		)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	httputil.RespondJSON(rw, jitConfigResponse{
+		RunnerID:         config.Runner.GetID(),
+		EncodedJITConfig: config.EncodedJITConfig,
+		URL:              target.target.URL(),
+	})
+}
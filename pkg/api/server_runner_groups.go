@@ -0,0 +1,212 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/gorilla/mux"
+	gh "github.com/oursky/github-actions-manager/pkg/github"
+	"github.com/oursky/github-actions-manager/pkg/utils/httputil"
+
+	"go.uber.org/zap"
+)
+
+type runnerGroupResponse struct {
+	ID                       int64  `json:"id"`
+	Name                     string `json:"name"`
+	Visibility               string `json:"visibility"`
+	Default                  bool   `json:"default"`
+	Inherited                bool   `json:"inherited"`
+	AllowsPublicRepositories bool   `json:"allowsPublicRepositories"`
+}
+
+type runnerGroupRequest struct {
+	Name                     *string `json:"name"`
+	Visibility               *string `json:"visibility"`
+	AllowsPublicRepositories *bool   `json:"allowsPublicRepositories"`
+	RepositoryIDs            []int64 `json:"repositoryIDs"`
+}
+
+type runnerGroupRepositoryResponse struct {
+	ID       int64  `json:"id"`
+	FullName string `json:"fullName"`
+}
+
+type runnerGroupRepositoriesRequest struct {
+	RepositoryIDs []int64 `json:"repositoryIDs"`
+}
+
+func newRunnerGroupResponse(g *github.RunnerGroup) runnerGroupResponse {
+	return runnerGroupResponse{
+		ID:                       g.GetID(),
+		Name:                     g.GetName(),
+		Visibility:               g.GetVisibility(),
+		Default:                  g.GetDefault(),
+		Inherited:                g.GetInherited(),
+		AllowsPublicRepositories: g.GetAllowsPublicRepositories(),
+	}
+}
+
+func (s *Server) respondTargetError(rw http.ResponseWriter, target *target, msg string, err error) {
+	if errors.Is(err, gh.ErrUnsupported) {
+		http.Error(rw, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	var respErr *github.ErrorResponse
+	if errors.As(err, &respErr) && respErr.Response != nil && respErr.Response.StatusCode == http.StatusNotFound {
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	s.logger.Warn(msg, zap.Error(err), zap.String("target", target.id))
+	http.Error(rw, err.Error(), http.StatusInternalServerError)
+}
+
+func parseIDParam(rw http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func (s *Server) apiRunnerGroupsGet(rw http.ResponseWriter, r *http.Request) {
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	groups, err := target.target.ListRunnerGroups(r.Context())
+	if err != nil {
+		s.respondTargetError(rw, target, "failed to list runner groups", err)
+		return
+	}
+
+	resp := []runnerGroupResponse{}
+	for _, g := range groups {
+		resp = append(resp, newRunnerGroupResponse(g))
+	}
+	httputil.RespondJSON(rw, resp)
+}
+
+func (s *Server) apiRunnerGroupPost(rw http.ResponseWriter, r *http.Request) {
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	var req runnerGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Name == nil || *req.Name == "" {
+		http.Error(rw, "empty runner group name", http.StatusBadRequest)
+		return
+	}
+
+	group, err := target.target.CreateRunnerGroup(r.Context(), github.CreateRunnerGroupRequest{
+		Name:                     req.Name,
+		Visibility:               req.Visibility,
+		AllowsPublicRepositories: req.AllowsPublicRepositories,
+		SelectedRepositoryIDs:    req.RepositoryIDs,
+	})
+	if err != nil {
+		s.respondTargetError(rw, target, "failed to create runner group", err)
+		return
+	}
+
+	s.logger.Info("created runner group",
+		zap.String("target", target.id),
+		zap.Int64("id", group.GetID()),
+		zap.String("name", group.GetName()),
+	)
+	httputil.RespondJSON(rw, newRunnerGroupResponse(group))
+}
+
+func (s *Server) apiRunnerGroupPatch(rw http.ResponseWriter, r *http.Request) {
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(rw, r)
+	if !ok {
+		return
+	}
+
+	var req runnerGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.RepositoryIDs != nil {
+		http.Error(rw, "use repositories endpoint to update repository access", http.StatusBadRequest)
+		return
+	}
+
+	group, err := target.target.UpdateRunnerGroup(r.Context(), id, github.UpdateRunnerGroupRequest{
+		Name:                     req.Name,
+		Visibility:               req.Visibility,
+		AllowsPublicRepositories: req.AllowsPublicRepositories,
+	})
+	if err != nil {
+		s.respondTargetError(rw, target, "failed to update runner group", err)
+		return
+	}
+
+	httputil.RespondJSON(rw, newRunnerGroupResponse(group))
+}
+
+func (s *Server) apiRunnerGroupRepositoriesGet(rw http.ResponseWriter, r *http.Request) {
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(rw, r)
+	if !ok {
+		return
+	}
+
+	repos, err := target.target.ListRunnerGroupRepositories(r.Context(), id)
+	if err != nil {
+		s.respondTargetError(rw, target, "failed to list runner group repositories", err)
+		return
+	}
+
+	resp := []runnerGroupRepositoryResponse{}
+	for _, repo := range repos {
+		resp = append(resp, runnerGroupRepositoryResponse{
+			ID:       repo.GetID(),
+			FullName: repo.GetFullName(),
+		})
+	}
+	httputil.RespondJSON(rw, resp)
+}
+
+func (s *Server) apiRunnerGroupRepositoriesPut(rw http.ResponseWriter, r *http.Request) {
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(rw, r)
+	if !ok {
+		return
+	}
+
+	var req runnerGroupRepositoriesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := target.target.SetRunnerGroupRepositories(r.Context(), id, req.RepositoryIDs)
+	if err != nil {
+		s.respondTargetError(rw, target, "failed to set runner group repositories", err)
+		return
+	}
+
+	rw.WriteHeader(200)
+}
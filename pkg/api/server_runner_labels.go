@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/gorilla/mux"
+	"github.com/oursky/github-actions-manager/pkg/utils/httputil"
+
+	"go.uber.org/zap"
+)
+
+type runnerLabelResponse struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type runnerLabelsRequest struct {
+	Labels []string `json:"labels"`
+}
+
+func (s *Server) respondRunnerLabels(rw http.ResponseWriter, labels []*github.RunnerLabels) {
+	resp := []runnerLabelResponse{}
+	for _, l := range labels {
+		resp = append(resp, runnerLabelResponse{Name: l.GetName(), Type: l.GetType()})
+	}
+	httputil.RespondJSON(rw, resp)
+}
+
+func (s *Server) apiRunnerLabelsGet(rw http.ResponseWriter, r *http.Request) {
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(rw, r)
+	if !ok {
+		return
+	}
+
+	labels, err := target.target.ListRunnerLabels(r.Context(), id)
+	if err != nil {
+		s.respondTargetError(rw, target, "failed to list runner labels", err)
+		return
+	}
+
+	s.respondRunnerLabels(rw, labels)
+}
+
+func (s *Server) apiRunnerLabelsUpdate(rw http.ResponseWriter, r *http.Request) {
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(rw, r)
+	if !ok {
+		return
+	}
+
+	var req runnerLabelsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var labels []*github.RunnerLabels
+	var err error
+	if r.Method == http.MethodPut {
+		labels, err = target.target.SetRunnerLabels(r.Context(), id, req.Labels)
+	} else {
+		if len(req.Labels) == 0 {
+			http.Error(rw, "empty labels", http.StatusBadRequest)
+			return
+		}
+		labels, err = target.target.AddRunnerLabels(r.Context(), id, req.Labels)
+	}
+	if err != nil {
+		s.respondTargetError(rw, target, "failed to update runner labels", err)
+		return
+	}
+
+	s.logger.Info("updated runner labels",
+		zap.String("target", target.id),
+		zap.Int64("id", id),
+		zap.String("method", r.Method),
+		zap.Strings("labels", req.Labels),
+	)
+	s.respondRunnerLabels(rw, labels)
+}
+
+func (s *Server) apiRunnerLabelDelete(rw http.ResponseWriter, r *http.Request) {
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(rw, r)
+	if !ok {
+		return
+	}
+	label := mux.Vars(r)["label"]
+
+	labels, err := target.target.RemoveRunnerLabel(r.Context(), id, label)
+	if err != nil {
+		s.respondTargetError(rw, target, "failed to remove runner label", err)
+		return
+	}
+
+	s.logger.Info("removed runner label",
+		zap.String("target", target.id),
+		zap.Int64("id", id),
+		zap.String("label", label),
+	)
+	s.respondRunnerLabels(rw, labels)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/oursky/github-actions-manager/pkg/github/runners"
+	"github.com/oursky/github-actions-manager/pkg/utils/httputil"
 
 	"go.uber.org/zap"
 )
@@ -16,7 +17,16 @@ type runnerResponse struct {
 	Runners []runners.Instance `json:"runners"`
 }
 
+type runnerEventsResponse struct {
+	Events []runners.Event `json:"events"`
+}
+
 func (s *Server) apiRunnerDelete(rw http.ResponseWriter, r *http.Request) {
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+
 	params := mux.Vars(r)
 	idstr := params["id"]
 
@@ -26,17 +36,27 @@ func (s *Server) apiRunnerDelete(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = s.target.DeleteRunner(r.Context(), id)
+	err = target.target.DeleteRunner(r.Context(), id)
 	if err != nil {
-		s.logger.Warn("failed to delete runner", zap.Error(err), zap.Int64("id", id))
+		s.logger.Warn("failed to delete runner",
+			zap.Error(err),
+			zap.String("target", target.id),
+			zap.Int64("id", id),
+		)
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	rw.WriteHeader(200)
 }
 
 func (s *Server) apiRunnersGet(rw http.ResponseWriter, r *http.Request) {
-	state := s.runners.State().Value()
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	state := target.runners.State().Value()
 
 	var instances []runners.Instance
 	for _, i := range state.Instances {
@@ -48,3 +68,28 @@ func (s *Server) apiRunnersGet(rw http.ResponseWriter, r *http.Request) {
 	rw.WriteHeader(200)
 	json.NewEncoder(rw).Encode(resp)
 }
+
+// apiRunnerEventsGet returns recent runner events after sequence number in
+// "after" query parameter.
+func (s *Server) apiRunnerEventsGet(rw http.ResponseWriter, r *http.Request) {
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	var after uint64
+	if value := r.URL.Query().Get("after"); value != "" {
+		seq, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		after = seq
+	}
+
+	events := target.runners.Events().Value().Since(after)
+	if events == nil {
+		events = []runners.Event{}
+	}
+	httputil.RespondJSON(rw, runnerEventsResponse{Events: events})
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/oursky/github-actions-manager/pkg/utils/httputil"
+)
+
+type targetResponse struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+func (s *Server) lookupTarget(rw http.ResponseWriter, r *http.Request) (*target, bool) {
+	id, ok := mux.Vars(r)["target"]
+	if !ok {
+		return s.defaultTarget, true
+	}
+
+	t, ok := s.targets[id]
+	if !ok {
+		http.Error(rw, "target not found", http.StatusNotFound)
+		return nil, false
+	}
+	return t, true
+}
+
+func (s *Server) apiTargetsGet(rw http.ResponseWriter, r *http.Request) {
+	targets := []targetResponse{}
+	for _, id := range s.targetIDs {
+		targets = append(targets, targetResponse{
+			ID:  id,
+			URL: s.targets[id].target.URL(),
+		})
+	}
+	httputil.RespondJSON(rw, targets)
+}
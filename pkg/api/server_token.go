@@ -7,7 +7,12 @@ import (
 )
 
 func (s *Server) apiToken(rw http.ResponseWriter, r *http.Request) {
-	token, err := s.regToken.Get(r.Context())
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	token, err := target.regToken.Get(r.Context())
 	if err != nil {
 		rw.WriteHeader(500)
 		rw.Write([]byte(err.Error()))
@@ -20,6 +25,6 @@ func (s *Server) apiToken(rw http.ResponseWriter, r *http.Request) {
 	}
 	httputil.RespondJSON(rw, resp{
 		Token: token,
-		URL:   s.target.URL(),
+		URL:   target.target.URL(),
 	})
 }
@@ -0,0 +1,29 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/utils/httputil"
+)
+
+const defaultUtilizationWindow = 24 * time.Hour
+
+func (s *Server) apiUtilizationGet(rw http.ResponseWriter, r *http.Request) {
+	target, ok := s.lookupTarget(rw, r)
+	if !ok {
+		return
+	}
+
+	window := defaultUtilizationWindow
+	if value := r.URL.Query().Get("window"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			http.Error(rw, "invalid window", http.StatusBadRequest)
+			return
+		}
+		window = d
+	}
+
+	httputil.RespondJSON(rw, target.runners.Utilization(window))
+}
@@ -7,12 +7,21 @@ import (
 )
 
 type Config struct {
-	ManagerURL        string  `validate:"required,url"`
-	ManagerAuthKey    string  `validate:"required"`
-	Addr              *string `validate:"omitempty,tcp_addr"`
+	ManagerURL     string  `validate:"required,url"`
+	ManagerAuthKey string  `validate:"required"`
+	TargetID       *string `validate:"omitempty,excludesall=/"`
+	Addr           *string `validate:"omitempty,tcp_addr"`
+	// DisableUpdate is not supported by just-in-time runners; it is only
+	// kept so that existing configuration is accepted.
 	DisableUpdate     *bool
 	SyncInterval      *time.Duration
 	TransitionTimeout *time.Duration
+	// CreateRunnerGroups creates requested runner groups that do not exist.
+	CreateRunnerGroups *bool
+}
+
+func (c *Config) GetCreateRunnerGroups() bool {
+	return defaults.Value(c.CreateRunnerGroups, false)
 }
 
 // FIXME: configure it at manager instead of controller
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
@@ -10,13 +11,15 @@ import (
 	"time"
 
 	"github.com/oursky/github-actions-manager/pkg/github/runners"
+	"github.com/oursky/github-actions-manager/pkg/utils/defaults"
 	"github.com/oursky/github-actions-manager/pkg/utils/httputil"
 )
 
 type managerAPI struct {
-	client *http.Client
-	base   url.URL
-	key    string
+	client   *http.Client
+	base     url.URL
+	key      string
+	targetID string
 }
 
 func newManagerAPI(config *Config) *managerAPI {
@@ -25,12 +28,23 @@ func newManagerAPI(config *Config) *managerAPI {
 		panic(err)
 	}
 	return &managerAPI{
-		client: &http.Client{Timeout: 10 * time.Second},
-		base:   *url,
-		key:    config.ManagerAuthKey,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		base:     *url,
+		key:      config.ManagerAuthKey,
+		targetID: defaults.Value(config.TargetID, ""),
 	}
 }
 
+func (m *managerAPI) url(apiPath string) string {
+	url := m.base
+	if m.targetID == "" {
+		url.Path = path.Join(url.Path, "api/v1", apiPath)
+	} else {
+		url.Path = path.Join(url.Path, "api/v1/targets", m.targetID, apiPath)
+	}
+	return url.String()
+}
+
 func (m *managerAPI) doJSON(r *http.Request, result any) error {
 	resp, err := m.client.Do(r)
 	if err != nil {
@@ -45,32 +59,44 @@ func (m *managerAPI) doJSON(r *http.Request, result any) error {
 	return json.NewDecoder(resp.Body).Decode(&result)
 }
 
-func (m *managerAPI) GetRegistrationToken(ctx context.Context) (token string, targetURL string, err error) {
-	url := m.base
-	url.Path = path.Join(url.Path, "api/v1/token")
+func (m *managerAPI) GenerateJITConfig(
+	ctx context.Context,
+	name string,
+	group string,
+	labels []string,
+	workFolder string,
+) (config string, runnerID int64, targetURL string, err error) {
+	body, err := json.Marshal(map[string]any{
+		"name":       name,
+		"group":      group,
+		"labels":     labels,
+		"workFolder": workFolder,
+	})
+	if err != nil {
+		return "", 0, "", err
+	}
 
-	r, err := http.NewRequestWithContext(ctx, "GET", url.String(), nil)
-	r.Header.Add("Authorization", "Bearer "+m.key)
+	r, err := http.NewRequestWithContext(ctx, "POST", m.url("jitconfig"), bytes.NewReader(body))
 	if err != nil {
-		return "", "", err
+		return "", 0, "", err
 	}
+	r.Header.Add("Authorization", "Bearer "+m.key)
+	r.Header.Set("Content-Type", "application/json")
 
 	var resp struct {
-		Token string `json:"token"`
-		URL   string `json:"url"`
+		RunnerID         int64  `json:"runnerID"`
+		EncodedJITConfig string `json:"encodedJITConfig"`
+		URL              string `json:"url"`
 	}
 	if err := m.doJSON(r, &resp); err != nil {
-		return "", "", err
+		return "", 0, "", err
 	}
 
-	return resp.Token, resp.URL, nil
+	return resp.EncodedJITConfig, resp.RunnerID, resp.URL, nil
 }
 
 func (m *managerAPI) GetRunners(ctx context.Context) (epoch int64, instances map[string]runners.Instance, err error) {
-	url := m.base
-	url.Path = path.Join(url.Path, "api/v1/runners")
-
-	r, err := http.NewRequestWithContext(ctx, "GET", url.String(), nil)
+	r, err := http.NewRequestWithContext(ctx, "GET", m.url("runners"), nil)
 	r.Header.Add("Authorization", "Bearer "+m.key)
 	if err != nil {
 		return 0, nil, err
@@ -93,10 +119,7 @@ func (m *managerAPI) GetRunners(ctx context.Context) (epoch int64, instances map
 }
 
 func (m *managerAPI) DeleteRunner(ctx context.Context, id int64) error {
-	url := m.base
-	url.Path = path.Join(url.Path, "api/v1/runners/"+strconv.FormatInt(id, 10))
-
-	r, err := http.NewRequestWithContext(ctx, "DELETE", url.String(), nil)
+	r, err := http.NewRequestWithContext(ctx, "DELETE", m.url("runners/"+strconv.FormatInt(id, 10)), nil)
 	r.Header.Add("Authorization", "Bearer "+m.key)
 	if err != nil {
 		return err
@@ -110,3 +133,62 @@ func (m *managerAPI) DeleteRunner(ctx context.Context, id int64) error {
 
 	return httputil.CheckStatus(resp)
 }
+
+type RunnerGroup struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Visibility string `json:"visibility"`
+}
+
+type RunnerGroupRepository struct {
+	ID       int64  `json:"id"`
+	FullName string `json:"fullName"`
+}
+
+func (m *managerAPI) GetRunnerGroups(ctx context.Context) ([]RunnerGroup, error) {
+	r, err := http.NewRequestWithContext(ctx, "GET", m.url("runner-groups"), nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Add("Authorization", "Bearer "+m.key)
+
+	var groups []RunnerGroup
+	if err := m.doJSON(r, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (m *managerAPI) CreateRunnerGroup(ctx context.Context, name string) (*RunnerGroup, error) {
+	body, err := json.Marshal(map[string]any{"name": name})
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := http.NewRequestWithContext(ctx, "POST", m.url("runner-groups"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Add("Authorization", "Bearer "+m.key)
+	r.Header.Set("Content-Type", "application/json")
+
+	group := new(RunnerGroup)
+	if err := m.doJSON(r, group); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (m *managerAPI) GetRunnerGroupRepositories(ctx context.Context, id int64) ([]RunnerGroupRepository, error) {
+	r, err := http.NewRequestWithContext(ctx, "GET", m.url("runner-groups/"+strconv.FormatInt(id, 10)+"/repositories"), nil)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Add("Authorization", "Bearer "+m.key)
+
+	var repos []RunnerGroupRepository
+	if err := m.doJSON(r, &repos); err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -12,14 +13,19 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
 )
 
 type server struct {
-	logger        *zap.Logger
-	server        *http.Server
-	managerAPI    *managerAPI
-	provider      Provider
-	disableUpdate bool
+	logger     *zap.Logger
+	server     *http.Server
+	managerAPI *managerAPI
+	provider   Provider
+
+	createRunnerGroups bool
+	runnerGroupsLock   sync.Mutex
+	runnerGroupsFlight singleflight.Group
+	runnerGroups       map[string]runnerGroupEntry
 }
 
 func newServer(logger *zap.Logger, config *Config, managerAPI *managerAPI, gatherer prometheus.Gatherer, provider Provider) *server {
@@ -35,9 +41,15 @@ func newServer(logger *zap.Logger, config *Config, managerAPI *managerAPI, gathe
 			Handler:      r,
 			ErrorLog:     zap.NewStdLog(logger),
 		},
-		managerAPI:    managerAPI,
-		provider:      provider,
-		disableUpdate: config.GetDisableUpdate(),
+		managerAPI: managerAPI,
+		provider:   provider,
+
+		createRunnerGroups: config.GetCreateRunnerGroups(),
+		runnerGroups:       make(map[string]runnerGroupEntry),
+	}
+
+	if config.GetDisableUpdate() {
+		logger.Warn("disableUpdate is not supported by just-in-time runners; pin runner version in image instead")
 	}
 
 	r.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
@@ -1,22 +1,29 @@
 package controller
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/oursky/github-actions-manager/pkg/github"
 	"github.com/oursky/github-actions-manager/pkg/utils/httputil"
 
 	"go.uber.org/zap"
 )
 
 type AgentResponse struct {
-	Agent         Agent    `json:"agent"`
-	TargetURL     string   `json:"targetURL"`
-	Token         string   `json:"token"`
-	Group         string   `json:"group"`
-	Labels        []string `json:"labels"`
-	DisableUpdate *bool    `json:"disableUpdate"`
+	Agent     Agent    `json:"agent"`
+	TargetURL string   `json:"targetURL"`
+	JITConfig string   `json:"jitConfig"`
+	Group     string   `json:"group"`
+	Labels    []string `json:"labels"`
+	// Repositories are repositories (owner/name) that must be allowed to use
+	// the runner group.
+	Repositories []string `json:"repositories,omitempty"`
 }
 
 func (s *server) apiAgentGet(rw http.ResponseWriter, r *http.Request) {
@@ -69,20 +76,186 @@ func (s *server) apiAgentPost(rw http.ResponseWriter, r *http.Request) {
 	hostName := r.FormValue("hostName")
 	if hostName == "" {
 		http.Error(rw, "empty hostName", http.StatusBadRequest)
+		return
 	}
+	workDir := r.FormValue("workDir")
+	hostOS := r.FormValue("os")
+	hostArch := r.FormValue("arch")
 
-	regToken, targetURL, err := s.managerAPI.GetRegistrationToken(r.Context())
+	resp, err := s.provider.RegisterAgent(r, hostName)
 	if err != nil {
-		s.logger.Error("cannot get registration token", zap.Error(err))
-		http.Error(rw, "cannot get registration token", http.StatusInternalServerError)
+		s.logger.Error("cannot register agent", zap.Error(err), zap.String("hostName", hostName))
+		http.Error(rw, "cannot register agent", http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.ensureRunnerGroup(r.Context(), resp.Group, resp.Repositories); err != nil {
+		s.logger.Error("invalid runner group",
+			zap.Error(err),
+			zap.String("id", resp.Agent.ID),
+			zap.String("group", resp.Group),
+		)
+		s.abortAgent(resp.Agent.ID)
+		http.Error(rw, "invalid runner group", http.StatusInternalServerError)
 		return
 	}
 
-	resp, err := s.provider.RegisterAgent(r, hostName, regToken, targetURL, s.disableUpdate)
+	// JIT runners do not get default labels from config.sh.
+	resp.Labels = append(github.RunnerDefaultLabels(hostOS, hostArch), resp.Labels...)
+
+	jitConfig, runnerID, targetURL, err := s.managerAPI.GenerateJITConfig(
+		r.Context(),
+		resp.Agent.RunnerName,
+		resp.Group,
+		resp.Labels,
+		workDir,
+	)
 	if err != nil {
-		s.logger.Error("cannot register agent", zap.Error(err), zap.String("hostName", hostName))
+		s.logger.Error("cannot generate runner config", zap.Error(err), zap.String("id", resp.Agent.ID))
+		// Runner group may be deleted since cached.
+		s.invalidateRunnerGroup(resp.Group)
+		s.abortAgent(resp.Agent.ID)
+		http.Error(rw, "cannot generate runner config", http.StatusInternalServerError)
+		return
+	}
+
+	err = s.provider.State().UpdateAgent(resp.Agent.ID, func(a *Agent) {
+		a.RunnerID = &runnerID
+	})
+	if err != nil {
+		s.logger.Error("cannot update agent", zap.Error(err), zap.String("id", resp.Agent.ID))
+		s.abortAgent(resp.Agent.ID)
 		http.Error(rw, "cannot register agent", http.StatusInternalServerError)
 		return
 	}
+
+	s.logger.Info("generated runner config",
+		zap.String("id", resp.Agent.ID),
+		zap.Int64("runnerID", runnerID),
+		zap.String("url", targetURL),
+	)
+
+	resp.Agent.RunnerID = &runnerID
+	resp.TargetURL = targetURL
+	resp.JITConfig = jitConfig
 	httputil.RespondJSON(rw, resp)
 }
+
+// runnerGroupCacheTTL is the duration runner groups are cached for, so that
+// deleted groups and changes of repository access are noticed eventually.
+const runnerGroupCacheTTL = 5 * time.Minute
+
+type runnerGroupEntry struct {
+	group     RunnerGroup
+	repos     map[string]struct{}
+	fetchedAt time.Time
+}
+
+// ensureRunnerGroup checks the runner group exists and repositories are
+// allowed to use it, creating the group if configured.
+func (s *server) ensureRunnerGroup(ctx context.Context, name string, repos []string) error {
+	if name == "" {
+		return nil
+	}
+
+	entry, err := s.getRunnerGroup(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if entry.group.Visibility != "selected" {
+		return nil
+	}
+	for _, repo := range repos {
+		if _, ok := entry.repos[strings.ToLower(repo)]; !ok {
+			return fmt.Errorf("repository %s is not allowed to use runner group %s", repo, name)
+		}
+	}
+	return nil
+}
+
+func (s *server) getRunnerGroup(ctx context.Context, name string) (runnerGroupEntry, error) {
+	s.runnerGroupsLock.Lock()
+	entry, ok := s.runnerGroups[name]
+	s.runnerGroupsLock.Unlock()
+	if ok && time.Since(entry.fetchedAt) < runnerGroupCacheTTL {
+		return entry, nil
+	}
+
+	// Fetch group once for concurrent registrations, so that a new group is
+	// not created more than once.
+	v, err, _ := s.runnerGroupsFlight.Do(name, func() (interface{}, error) {
+		return s.fetchRunnerGroup(ctx, name)
+	})
+	if err != nil {
+		return runnerGroupEntry{}, err
+	}
+	return v.(runnerGroupEntry), nil
+}
+
+func (s *server) fetchRunnerGroup(ctx context.Context, name string) (runnerGroupEntry, error) {
+	groups, err := s.managerAPI.GetRunnerGroups(ctx)
+	if err != nil {
+		return runnerGroupEntry{}, err
+	}
+
+	var group *RunnerGroup
+	for i, g := range groups {
+		if g.Name == name {
+			group = &groups[i]
+			break
+		}
+	}
+	if group == nil {
+		if !s.createRunnerGroups {
+			s.invalidateRunnerGroup(name)
+			return runnerGroupEntry{}, fmt.Errorf("runner group not found: %s", name)
+		}
+
+		group, err = s.managerAPI.CreateRunnerGroup(ctx, name)
+		if err != nil {
+			return runnerGroupEntry{}, fmt.Errorf("cannot create runner group %s: %w", name, err)
+		}
+		s.logger.Info("created runner group", zap.String("name", group.Name), zap.Int64("id", group.ID))
+	}
+
+	entry := runnerGroupEntry{group: *group, fetchedAt: time.Now()}
+	if group.Visibility == "selected" {
+		repos, err := s.managerAPI.GetRunnerGroupRepositories(ctx, group.ID)
+		if errors.Is(err, httputil.ErrHTTPStatus(http.StatusNotFound)) {
+			s.invalidateRunnerGroup(name)
+			return runnerGroupEntry{}, fmt.Errorf("runner group not found: %s", name)
+		} else if err != nil {
+			return runnerGroupEntry{}, err
+		}
+
+		entry.repos = make(map[string]struct{})
+		for _, r := range repos {
+			entry.repos[strings.ToLower(r.FullName)] = struct{}{}
+		}
+	}
+
+	s.runnerGroupsLock.Lock()
+	s.runnerGroups[name] = entry
+	s.runnerGroupsLock.Unlock()
+	return entry, nil
+}
+
+func (s *server) invalidateRunnerGroup(name string) {
+	s.runnerGroupsLock.Lock()
+	defer s.runnerGroupsLock.Unlock()
+
+	delete(s.runnerGroups, name)
+}
+
+// abortAgent terminates an agent that failed to complete registration; the
+// registered runner, if any, would be removed by monitor.
+func (s *server) abortAgent(id string) {
+	err := s.provider.State().UpdateAgent(id, func(a *Agent) {
+		a.State = AgentStateTerminating
+		a.LastTransitionTime = time.Now()
+	})
+	if err != nil {
+		s.logger.Error("failed to terminate agent", zap.Error(err), zap.String("id", id))
+	}
+}
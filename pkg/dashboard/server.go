@@ -22,6 +22,7 @@ import (
 )
 
 type RunnersState interface {
+	ID() string
 	State() *channels.Broadcaster[*runners.State]
 }
 
@@ -35,11 +36,11 @@ type Server struct {
 	server  *http.Server
 	assets  fs.FS
 
-	runners RunnersState
+	runners []RunnersState
 	jobs    JobsState
 }
 
-func NewServer(logger *zap.Logger, config *Config, runners RunnersState, jobs JobsState) *Server {
+func NewServer(logger *zap.Logger, config *Config, runners []RunnersState, jobs JobsState) *Server {
 	if config.Disabled {
 		return &Server{enabled: false}
 	}
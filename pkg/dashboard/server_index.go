@@ -9,21 +9,32 @@ import (
 )
 
 type dataIndex struct {
-	Runners      []runners.Instance
+	Runners      []runner
+	ShowTarget   bool
 	WorkflowRuns []*jobs.WorkflowRun
 	RunnerJobMap map[int64]*jobs.WorkflowJob
 }
 
+type runner struct {
+	runners.Instance
+	Target string
+}
+
 func (s *Server) index(rw http.ResponseWriter, r *http.Request) {
 	_ = r.ParseForm()
 	isAll := r.Form.Has("all")
 
-	rState := s.runners.State().Value()
-	var runners []runners.Instance
-	for _, i := range rState.Instances {
-		runners = append(runners, i)
+	var runners []runner
+	for _, r := range s.runners {
+		rState := r.State().Value()
+		for _, i := range rState.Instances {
+			runners = append(runners, runner{Instance: i, Target: r.ID()})
+		}
 	}
 	sort.Slice(runners, func(i, j int) bool {
+		if runners[i].Target != runners[j].Target {
+			return runners[i].Target < runners[j].Target
+		}
 		return runners[i].ID < runners[j].ID
 	})
 
@@ -47,6 +58,7 @@ func (s *Server) index(rw http.ResponseWriter, r *http.Request) {
 
 	data := &dataIndex{
 		Runners:      runners,
+		ShowTarget:   len(s.runners) > 1,
 		WorkflowRuns: runs,
 		RunnerJobMap: jobMap,
 	}
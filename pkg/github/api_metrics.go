@@ -0,0 +1,205 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gregjones/httpcache"
+	"github.com/oursky/github-actions-manager/pkg/utils/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type moduleKey struct{}
+
+// WithModule tags GitHub API calls made with the context by module name.
+func WithModule(ctx context.Context, module string) context.Context {
+	return context.WithValue(ctx, moduleKey{}, module)
+}
+
+func getModule(ctx context.Context) string {
+	if m, ok := ctx.Value(moduleKey{}).(string); ok {
+		return m
+	}
+	return "unknown"
+}
+
+// APIMetrics records metrics of outbound GitHub API calls.
+type APIMetrics struct {
+	requests           *prometheus.CounterVec
+	duration           *prometheus.HistogramVec
+	cache              *prometheus.CounterVec
+	limiterWait        *prometheus.HistogramVec
+	rateLimitRemaining *prometheus.GaugeVec
+	rateLimitReset     *prometheus.GaugeVec
+	credentialReloads  *prometheus.CounterVec
+}
+
+func NewAPIMetrics(registry *prometheus.Registry) *APIMetrics {
+	m := &APIMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "github_api",
+			Name:      "requests_total",
+			Help:      "Number of GitHub API requests.",
+		}, []string{"module", "method", "endpoint", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "github_api",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of GitHub API requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"module", "method", "endpoint"}),
+		cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "github_api",
+			Name:      "cache_requests_total",
+			Help:      "Number of cacheable GitHub API requests by cache result.",
+		}, []string{"module", "result"}),
+		limiterWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "github_api",
+			Name:      "limiter_wait_seconds",
+			Help:      "Time GitHub API requests waited for rate limiter.",
+			Buckets:   []float64{0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300},
+		}, []string{"module", "priority"}),
+		rateLimitRemaining: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "github_api",
+			Name:      "rate_limit_remaining",
+			Help:      "Last seen remaining GitHub API quota.",
+		}, []string{"credential"}),
+		rateLimitReset: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "github_api",
+			Name:      "rate_limit_reset_time",
+			Help:      "Last seen GitHub API quota reset time in unix timestamp.",
+		}, []string{"credential"}),
+		credentialReloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "github_api",
+			Name:      "credential_reloads_total",
+			Help:      "Number of credential reloads by result.",
+		}, []string{"credential", "result"}),
+	}
+	registry.MustRegister(
+		m.requests,
+		m.duration,
+		m.cache,
+		m.limiterWait,
+		m.rateLimitRemaining,
+		m.rateLimitReset,
+		m.credentialReloads,
+	)
+	return m
+}
+
+// Transport records requests; it should wrap the cached transport.
+func (m *APIMetrics) Transport(rt http.RoundTripper) http.RoundTripper {
+	return &metricsTransport{rt: rt, metrics: m}
+}
+
+// CredentialTransport records quota of a credential; it should wrap the
+// authenticated transport.
+func (m *APIMetrics) CredentialTransport(rt http.RoundTripper, credential string) http.RoundTripper {
+	return &credentialMetricsTransport{rt: rt, metrics: m, credential: credential}
+}
+
+func (m *APIMetrics) ObserveLimiterWait(r *http.Request, d time.Duration) {
+	priority := ratelimit.GetPriority(r.Context())
+	m.limiterWait.
+		WithLabelValues(getModule(r.Context()), priority.String()).
+		Observe(d.Seconds())
+}
+
+func (m *APIMetrics) ObserveCredentialReload(credential string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.credentialReloads.WithLabelValues(credential, result).Inc()
+}
+
+type metricsTransport struct {
+	rt      http.RoundTripper
+	metrics *APIMetrics
+}
+
+func (t *metricsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	module := getModule(r.Context())
+	endpoint := endpointTemplate(r.URL.Path)
+
+	start := time.Now()
+	resp, err := t.rt.RoundTrip(r)
+	duration := time.Since(start)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	t.metrics.requests.WithLabelValues(module, r.Method, endpoint, status).Inc()
+	t.metrics.duration.WithLabelValues(module, r.Method, endpoint).Observe(duration.Seconds())
+
+	if err == nil && r.Method == http.MethodGet {
+		result := "miss"
+		if resp.Header.Get(httpcache.XFromCache) != "" {
+			result = "hit"
+		}
+		t.metrics.cache.WithLabelValues(module, result).Inc()
+	}
+
+	return resp, err
+}
+
+type credentialMetricsTransport struct {
+	rt         http.RoundTripper
+	metrics    *APIMetrics
+	credential string
+}
+
+func (t *credentialMetricsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(r)
+	if err != nil {
+		return resp, err
+	}
+
+	if res := resp.Header.Get("X-RateLimit-Resource"); res != "" && res != "core" {
+		return resp, err
+	}
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		t.metrics.rateLimitRemaining.WithLabelValues(t.credential).Set(float64(remaining))
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		t.metrics.rateLimitReset.WithLabelValues(t.credential).Set(float64(reset))
+	}
+	return resp, err
+}
+
+var regexNumeric = regexp.MustCompile(`^[0-9]+$`)
+
+// endpointTemplate converts API path to a low cardinality template, e.g.
+// /repos/{owner}/{repo}/actions/runs/{id}.
+func endpointTemplate(path string) string {
+	path = strings.TrimPrefix(path, "/api/v3")
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i := 0; i < len(segments); i++ {
+		switch {
+		case i == 0 && segments[i] == "repos" && len(segments) >= 3:
+			segments[1] = "{owner}"
+			segments[2] = "{repo}"
+			i += 2
+		case i == 0 && segments[i] == "orgs" && len(segments) >= 2:
+			segments[1] = "{org}"
+			i++
+		case i == 0 && segments[i] == "enterprises" && len(segments) >= 2:
+			segments[1] = "{enterprise}"
+			i++
+		case i == 0 && segments[i] == "users" && len(segments) >= 2:
+			segments[1] = "{user}"
+			i++
+		case segments[i] == "labels" && i+1 < len(segments):
+			segments[i+1] = "{name}"
+			i++
+		case regexNumeric.MatchString(segments[i]):
+			segments[i] = "{id}"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
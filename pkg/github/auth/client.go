@@ -1,12 +1,19 @@
 package auth
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/bradleyfalzon/ghinstallation/v2"
+	"go.uber.org/zap"
 	"golang.org/x/oauth2"
+	"golang.org/x/sync/errgroup"
 )
 
 type TokenTransport struct {
@@ -18,46 +25,190 @@ type AppTransport struct {
 	AppsTransport *ghinstallation.AppsTransport
 }
 
-func NewTransport(config *Config, base http.RoundTripper) (http.RoundTripper, error) {
+// Transport is an authenticated transport. Credentials loaded from files are
+// reloaded when the files change; in-flight requests keep using the previous
+// credentials.
+type Transport struct {
+	logger *zap.Logger
+	config *Config
+	apiURL string
+	base   http.RoundTripper
+
+	// OnReload is called with result of each credential reload, if set.
+	OnReload func(err error)
+
+	credential []byte
+	transport  atomic.Value
+}
+
+// NewTransport creates an authenticated transport. apiURL is the GitHub API
+// base URL, or empty for github.com.
+func NewTransport(logger *zap.Logger, config *Config, apiURL string, base http.RoundTripper) (*Transport, error) {
+	t := &Transport{
+		logger: logger.Named("auth"),
+		config: config,
+		apiURL: apiURL,
+		base:   base,
+	}
+
+	credential, err := t.loadCredential()
+	if err != nil {
+		return nil, err
+	}
+	transport, err := t.newTransport(credential)
+	if err != nil {
+		return nil, err
+	}
+
+	t.credential = credential
+	t.transport.Store(transport)
+	return t, nil
+}
+
+type appAuthKey struct{}
+
+// WithAppAuth makes GitHub API calls made with the context authenticate as
+// the GitHub App itself instead of its installation, e.g. to access app
+// webhook deliveries. It has no effect on other types of credentials.
+func WithAppAuth(ctx context.Context) context.Context {
+	return context.WithValue(ctx, appAuthKey{}, true)
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	transport := t.transport.Load().(http.RoundTripper)
+	if app, ok := transport.(AppTransport); ok {
+		if appAuth, _ := r.Context().Value(appAuthKey{}).(bool); appAuth {
+			return app.AppsTransport.RoundTrip(r)
+		}
+	}
+	return transport.RoundTrip(r)
+}
+
+func (t *Transport) Start(ctx context.Context, g *errgroup.Group) error {
+	if t.credentialPath() == "" {
+		return nil
+	}
+
+	g.Go(func() error {
+		t.run(ctx)
+		return nil
+	})
+	return nil
+}
+
+func (t *Transport) run(ctx context.Context) {
+	ticker := time.NewTicker(t.config.GetReloadInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.reload()
+		}
+	}
+}
+
+func (t *Transport) reload() {
+	credential, err := t.loadCredential()
+	if err == nil && bytes.Equal(credential, t.credential) {
+		return
+	}
+
 	var transport http.RoundTripper
-	switch config.Type {
+	if err == nil {
+		transport, err = t.newTransport(credential)
+	}
+
+	if t.OnReload != nil {
+		t.OnReload(err)
+	}
+	if err != nil {
+		t.logger.Warn("failed to reload credential",
+			zap.Error(err),
+			zap.String("path", t.credentialPath()),
+		)
+		return
+	}
+
+	t.credential = credential
+	t.transport.Store(transport)
+	t.logger.Info("reloaded credential", zap.String("path", t.credentialPath()))
+}
+
+func (t *Transport) credentialPath() string {
+	switch t.config.Type {
 	case TypeToken:
-		transport = TokenTransport{
-			Transport: &oauth2.Transport{
-				Base:   base,
-				Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: config.Token}),
-			},
+		return t.config.TokenPath
+	case TypeApp:
+		if t.config.App.PrivateKey == "" {
+			return t.config.App.PrivateKeyPath
 		}
+	}
+	return ""
+}
 
+func (t *Transport) loadCredential() ([]byte, error) {
+	var credential []byte
+	switch t.config.Type {
+	case TypeToken:
+		credential = []byte(t.config.Token)
 	case TypeApp:
-		privKey := []byte(config.App.PrivateKey)
-		if len(privKey) == 0 {
-			key, err := os.ReadFile(config.App.PrivateKeyPath)
-			if err != nil {
-				return nil, fmt.Errorf("failed to load app key: %w", err)
-			}
-			privKey = key
+		credential = []byte(t.config.App.PrivateKey)
+	default:
+		return nil, fmt.Errorf("invalid auth type: %s", t.config.Type)
+	}
+
+	if path := t.credentialPath(); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load credential: %w", err)
 		}
+		credential = data
+	}
+
+	if t.config.Type == TypeToken {
+		credential = bytes.TrimSpace(credential)
+	}
+	if len(credential) == 0 {
+		return nil, fmt.Errorf("empty credential")
+	}
+	return credential, nil
+}
 
-		appTransport, err := ghinstallation.NewAppsTransport(base,
-			config.App.AppID,
-			privKey,
+func (t *Transport) newTransport(credential []byte) (http.RoundTripper, error) {
+	switch t.config.Type {
+	case TypeToken:
+		return TokenTransport{
+			Transport: &oauth2.Transport{
+				Base:   t.base,
+				Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: string(credential)}),
+			},
+		}, nil
+
+	case TypeApp:
+		appTransport, err := ghinstallation.NewAppsTransport(t.base,
+			t.config.App.AppID,
+			credential,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load app key: %w", err)
 		}
+		if t.apiURL != "" {
+			appTransport.BaseURL = strings.TrimSuffix(t.apiURL, "/")
+		}
 
-		transport = AppTransport{
+		// Installation token is fetched again with the new key.
+		return AppTransport{
 			Transport: ghinstallation.NewFromAppsTransport(
 				appTransport,
-				config.App.InstallationID,
+				t.config.App.InstallationID,
 			),
 			AppsTransport: appTransport,
-		}
+		}, nil
 
 	default:
-		return nil, fmt.Errorf("invalid auth type: %s", config.Type)
+		return nil, fmt.Errorf("invalid auth type: %s", t.config.Type)
 	}
-
-	return transport, nil
 }
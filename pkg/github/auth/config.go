@@ -1,5 +1,11 @@
 package auth
 
+import (
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/utils/defaults"
+)
+
 type Type string
 
 const (
@@ -8,9 +14,16 @@ const (
 )
 
 type Config struct {
-	Type  Type           `validate:"required,oneof=Token App"`
-	Token string         `validate:"required_if=Type Token"`
-	App   *AppAuthConfig `validate:"required_if=Type App"`
+	Type      Type           `validate:"required,oneof=Token App"`
+	Token     string         `validate:"required_if=Type Token TokenPath ''"`
+	TokenPath string         `validate:"omitempty,file"`
+	App       *AppAuthConfig `validate:"required_if=Type App"`
+	// ReloadInterval is the interval to check credential files for changes.
+	ReloadInterval *time.Duration
+}
+
+func (c *Config) GetReloadInterval() time.Duration {
+	return defaults.Value(c.ReloadInterval, 1*time.Minute)
 }
 
 type AppAuthConfig struct {
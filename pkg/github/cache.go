@@ -0,0 +1,263 @@
+package github
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/kv"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// cacheKVNamespace keeps cache apart from other state, so that they are not
+// limited by size of a single ConfigMap together.
+var cacheKVNamespace = kv.RegisterNamespace("github-http-cache")
+
+const (
+	cacheKVKey           = "http-cache"
+	cacheFileName        = "http-cache.json"
+	cacheSnapshotVersion = 1
+)
+
+type cacheEntry struct {
+	Key  string `json:"key"`
+	Data []byte `json:"data"`
+}
+
+type cacheSnapshot struct {
+	Version int           `json:"version"`
+	Entries []*cacheEntry `json:"entries"`
+}
+
+type cacheStorage interface {
+	Load(ctx context.Context) (string, error)
+	Save(ctx context.Context, data string) error
+}
+
+// Cache is a size-bounded LRU HTTP cache, optionally persisted as snapshots.
+type Cache struct {
+	logger        *zap.Logger
+	storage       cacheStorage
+	maxSize       int64
+	maxEntries    int
+	flushInterval time.Duration
+
+	lock    *sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int64
+	dirty   bool
+}
+
+func NewCache(logger *zap.Logger, config *CacheConfig, store kv.Store) (*Cache, error) {
+	var storage cacheStorage
+	switch config.GetType() {
+	case CacheTypeMemory:
+		storage = nil
+	case CacheTypeStore:
+		storage = kvCacheStorage{store: store}
+	case CacheTypeDir:
+		storage = dirCacheStorage{path: filepath.Join(config.Dir, cacheFileName)}
+	default:
+		return nil, fmt.Errorf("invalid cache type: %s", config.Type)
+	}
+
+	return &Cache{
+		logger:        logger.Named("http-cache"),
+		storage:       storage,
+		maxSize:       config.GetMaxSize(),
+		maxEntries:    config.GetMaxEntries(),
+		flushInterval: config.GetFlushInterval(),
+		lock:          new(sync.Mutex),
+		entries:       make(map[string]*list.Element),
+		lru:           list.New(),
+	}, nil
+}
+
+func (c *Cache) Start(ctx context.Context, g *errgroup.Group) error {
+	if c.storage == nil {
+		return nil
+	}
+
+	c.load(ctx)
+
+	g.Go(func() error {
+		for {
+			select {
+			case <-ctx.Done():
+				flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				c.flush(flushCtx)
+				return nil
+
+			case <-time.After(c.flushInterval):
+				c.flush(ctx)
+			}
+		}
+	})
+	return nil
+}
+
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).Data, true
+}
+
+func (c *Cache) Set(key string, data []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.set(&cacheEntry{Key: key, Data: data})
+	c.dirty = true
+}
+
+func (c *Cache) Delete(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+		c.dirty = true
+	}
+}
+
+func (c *Cache) set(entry *cacheEntry) {
+	if elem, ok := c.entries[entry.Key]; ok {
+		c.remove(elem)
+	}
+	if int64(len(entry.Data)) > c.maxSize {
+		return
+	}
+
+	c.entries[entry.Key] = c.lru.PushFront(entry)
+	c.size += int64(len(entry.Data))
+
+	for c.size > c.maxSize || (c.maxEntries > 0 && c.lru.Len() > c.maxEntries) {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.Key)
+	c.size -= int64(len(entry.Data))
+}
+
+func (c *Cache) load(ctx context.Context) {
+	data, err := c.storage.Load(ctx)
+	if err != nil {
+		c.logger.Warn("failed to load cache", zap.Error(err))
+		return
+	} else if data == "" {
+		return
+	}
+
+	var snapshot cacheSnapshot
+	if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+		c.logger.Warn("failed to load cache", zap.Error(err))
+		return
+	}
+	if snapshot.Version != cacheSnapshotVersion {
+		c.logger.Info("ignored incompatible cache", zap.Int("version", snapshot.Version))
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Snapshot entries are ordered from least recently used.
+	for _, entry := range snapshot.Entries {
+		c.set(entry)
+	}
+	c.logger.Info("loaded cache", zap.Int("entries", c.lru.Len()), zap.Int64("size", c.size))
+}
+
+func (c *Cache) flush(ctx context.Context) {
+	data, ok := c.snapshot()
+	if !ok {
+		return
+	}
+
+	if err := c.storage.Save(ctx, data); err != nil {
+		c.logger.Warn("failed to save cache", zap.Error(err))
+		c.lock.Lock()
+		c.dirty = true
+		c.lock.Unlock()
+		return
+	}
+	c.logger.Debug("saved cache", zap.Int("size", len(data)))
+}
+
+func (c *Cache) snapshot() (string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.dirty {
+		return "", false
+	}
+	c.dirty = false
+
+	snapshot := cacheSnapshot{Version: cacheSnapshotVersion}
+	for elem := c.lru.Back(); elem != nil; elem = elem.Prev() {
+		snapshot.Entries = append(snapshot.Entries, elem.Value.(*cacheEntry))
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		c.logger.Warn("failed to encode cache", zap.Error(err))
+		return "", false
+	}
+	return string(data), true
+}
+
+type kvCacheStorage struct {
+	store kv.Store
+}
+
+func (s kvCacheStorage) Load(ctx context.Context) (string, error) {
+	return s.store.Get(ctx, cacheKVNamespace, cacheKVKey)
+}
+
+func (s kvCacheStorage) Save(ctx context.Context, data string) error {
+	return s.store.Set(ctx, cacheKVNamespace, cacheKVKey, data)
+}
+
+type dirCacheStorage struct {
+	path string
+}
+
+func (s dirCacheStorage) Load(ctx context.Context) (string, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (s dirCacheStorage) Save(ctx context.Context, data string) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
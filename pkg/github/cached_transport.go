@@ -10,10 +10,11 @@ type cachedTransport struct {
 	rt http.RoundTripper
 }
 
-func NewCachedTransport(rt http.RoundTripper) http.RoundTripper {
+func NewCachedTransport(rt http.RoundTripper, cache httpcache.Cache) http.RoundTripper {
 	return &httpcache.Transport{
-		Cache:     httpcache.NewMemoryCache(),
-		Transport: &cachedTransport{rt: rt},
+		Cache:               cache,
+		Transport:           &cachedTransport{rt: rt},
+		MarkCachedResponses: true,
 	}
 }
 
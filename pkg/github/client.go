@@ -0,0 +1,19 @@
+package github
+
+import (
+	"net/http"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// NewClient creates a GitHub API client. API and upload URLs are only needed
+// for GitHub Enterprise Server, e.g. https://github.example.com/api/v3/.
+func NewClient(http *http.Client, apiURL string, uploadURL string) (*github.Client, error) {
+	if apiURL == "" {
+		return github.NewClient(http), nil
+	}
+	if uploadURL == "" {
+		uploadURL = apiURL
+	}
+	return github.NewEnterpriseClient(apiURL, uploadURL, http)
+}
@@ -0,0 +1,46 @@
+package github
+
+import (
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/utils/defaults"
+)
+
+type CacheType string
+
+const (
+	CacheTypeMemory CacheType = "Memory"
+	CacheTypeStore  CacheType = "Store"
+	CacheTypeDir    CacheType = "Dir"
+)
+
+type CacheConfig struct {
+	Type          CacheType `validate:"omitempty,oneof=Memory Store Dir"`
+	Dir           string    `validate:"required_if=Type Dir"`
+	MaxSize       *int64    `validate:"omitempty,min=0"`
+	MaxEntries    *int      `validate:"omitempty,min=0"`
+	FlushInterval *time.Duration
+}
+
+func (c *CacheConfig) GetType() CacheType {
+	if c.Type == "" {
+		return CacheTypeMemory
+	}
+	return c.Type
+}
+
+func (c *CacheConfig) GetMaxSize() int64 {
+	if c.GetType() == CacheTypeStore {
+		// Store may be backed by a ConfigMap, which is limited to 1MiB.
+		return defaults.Value(c.MaxSize, 512*1024)
+	}
+	return defaults.Value(c.MaxSize, 32*1024*1024)
+}
+
+func (c *CacheConfig) GetMaxEntries() int {
+	return defaults.Value(c.MaxEntries, 4096)
+}
+
+func (c *CacheConfig) GetFlushInterval() time.Duration {
+	return defaults.Value(c.FlushInterval, 1*time.Minute)
+}
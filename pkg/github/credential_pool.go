@@ -0,0 +1,70 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/oursky/github-actions-manager/pkg/utils/ratelimit"
+)
+
+type adminCredentialKey struct{}
+
+// WithAdminCredential pins GitHub API calls made with the context to the
+// primary credential, which has admin rights on targets.
+func WithAdminCredential(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminCredentialKey{}, true)
+}
+
+func isAdminCredential(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminCredentialKey{}).(bool)
+	return admin
+}
+
+// CredentialPool spreads requests across credentials by their remaining
+// quota. The first credential is the primary credential.
+type CredentialPool struct {
+	credentials []*ratelimit.Transport
+	next        uint32
+}
+
+func NewCredentialPool(credentials []*ratelimit.Transport) *CredentialPool {
+	return &CredentialPool{credentials: credentials}
+}
+
+func (p *CredentialPool) RoundTrip(r *http.Request) (*http.Response, error) {
+	return p.choose(r.Context()).RoundTrip(r)
+}
+
+// Headroom returns the fraction of quota remaining of the credential with
+// most remaining quota.
+func (p *CredentialPool) Headroom() float64 {
+	headroom := 0.0
+	for _, c := range p.credentials {
+		if h := c.Headroom(); h > headroom {
+			headroom = h
+		}
+	}
+	return headroom
+}
+
+func (p *CredentialPool) choose(ctx context.Context) *ratelimit.Transport {
+	if isAdminCredential(ctx) || len(p.credentials) == 1 {
+		return p.credentials[0]
+	}
+
+	// Rotate starting point, so that credentials with equal headroom are
+	// used in turn.
+	start := int(atomic.AddUint32(&p.next, 1)) % len(p.credentials)
+
+	var best *ratelimit.Transport
+	bestHeadroom := -1.0
+	for i := range p.credentials {
+		c := p.credentials[(start+i)%len(p.credentials)]
+		if h := c.Headroom(); h > bestHeadroom {
+			best = c
+			bestHeadroom = h
+		}
+	}
+	return best
+}
@@ -0,0 +1,654 @@
+// Package githubtest provides an in-process fake GitHub API server for tests.
+package githubtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/gorilla/mux"
+)
+
+const (
+	apiPrefix      = "/api/v3"
+	rateLimitQuota = 5000
+)
+
+type repoKey struct {
+	Owner string
+	Repo  string
+	ID    int64
+}
+
+// Server is a fake GitHub API server. Runners are kept per scope, i.e. the
+// path prefix of actions API: "orgs/<org>", "repos/<owner>/<repo>" or
+// "enterprises/<slug>".
+type Server struct {
+	*httptest.Server
+
+	lock     sync.Mutex
+	nextID   int64
+	requests []string
+	tokens   int
+	used     int
+
+	runners map[string]map[int64]*github.Runner
+	groups  map[string][]*github.RunnerGroup
+	runs    map[repoKey]map[int]*github.WorkflowRun
+	jobs    map[repoKey]*workflowJob
+	usages  map[repoKey]*github.WorkflowRunUsage
+
+	deliveries   map[string][]*github.HookDelivery
+	redeliveries map[string][]int64
+
+	failures map[string]int
+}
+
+// workflowJob is a workflow job with its run attempt, which is missing in
+// the GitHub client model.
+type workflowJob struct {
+	*github.WorkflowJob
+	RunAttempt int `json:"run_attempt"`
+}
+
+func NewServer() *Server {
+	s := &Server{
+		nextID:  1000,
+		runners: make(map[string]map[int64]*github.Runner),
+		groups:  make(map[string][]*github.RunnerGroup),
+		runs:    make(map[repoKey]map[int]*github.WorkflowRun),
+		jobs:    make(map[repoKey]*workflowJob),
+		usages:  make(map[repoKey]*github.WorkflowRunUsage),
+
+		deliveries:   make(map[string][]*github.HookDelivery),
+		redeliveries: make(map[string][]int64),
+
+		failures: make(map[string]int),
+	}
+
+	r := mux.NewRouter()
+	api := r.PathPrefix(apiPrefix).Subrouter()
+	api.Use(s.middleware)
+	for _, scope := range []string{
+		"/orgs/{org}",
+		"/repos/{owner}/{repo}",
+		"/enterprises/{enterprise}",
+	} {
+		api.HandleFunc(scope+"/actions/runners", s.listRunners).Methods("GET")
+		api.HandleFunc(scope+"/actions/runners/registration-token", s.createRegistrationToken).Methods("POST")
+		api.HandleFunc(scope+"/actions/runners/generate-jitconfig", s.generateJITConfig).Methods("POST")
+		api.HandleFunc(scope+"/actions/runners/{id:[0-9]+}", s.deleteRunner).Methods("DELETE")
+		api.HandleFunc(scope+"/actions/runner-groups", s.listRunnerGroups).Methods("GET")
+	}
+	api.HandleFunc("/orgs/{org}/repos", s.listOrgRepos).Methods("GET")
+	api.HandleFunc("/repos/{owner}/{repo}/actions/runs", s.listRuns).Methods("GET")
+	api.HandleFunc("/repos/{owner}/{repo}/actions/runs/{id:[0-9]+}", s.getRun).Methods("GET")
+	api.HandleFunc("/repos/{owner}/{repo}/actions/runs/{id:[0-9]+}/jobs", s.listRunJobs).Methods("GET")
+	api.HandleFunc("/repos/{owner}/{repo}/actions/runs/{id:[0-9]+}/attempts/{attempt:[0-9]+}", s.getRun).Methods("GET")
+	api.HandleFunc("/repos/{owner}/{repo}/actions/runs/{id:[0-9]+}/attempts/{attempt:[0-9]+}/jobs", s.listRunJobs).Methods("GET")
+	api.HandleFunc("/repos/{owner}/{repo}/actions/runs/{id:[0-9]+}/timing", s.getRunUsage).Methods("GET")
+	api.HandleFunc("/repos/{owner}/{repo}/actions/jobs/{id:[0-9]+}", s.getJob).Methods("GET")
+	for _, hook := range []string{
+		"/repos/{owner}/{repo}/hooks/{hook:[0-9]+}",
+		"/orgs/{org}/hooks/{hook:[0-9]+}",
+		"/app/hook",
+	} {
+		api.HandleFunc(hook+"/deliveries", s.listHookDeliveries).Methods("GET")
+		api.HandleFunc(hook+"/deliveries/{id:[0-9]+}/attempts", s.redeliverHookDelivery).Methods("POST")
+	}
+
+	s.Server = httptest.NewServer(r)
+	return s
+}
+
+// APIURL returns the API base URL, for use as GitHub Enterprise Server URL.
+func (s *Server) APIURL() string {
+	return s.URL + apiPrefix + "/"
+}
+
+// Client returns a GitHub client connected to the server.
+func (s *Server) Client() *github.Client {
+	client, err := github.NewEnterpriseClient(s.APIURL(), s.APIURL(), s.Server.Client())
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// Requests returns the requests received, e.g. "GET /orgs/acme/actions/runners".
+func (s *Server) Requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// SetFailure makes requests, e.g. "GET /repos/acme/repo/actions/jobs/1", fail
+// with status; status 0 removes the failure.
+func (s *Server) SetFailure(request string, status int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if status == 0 {
+		delete(s.failures, request)
+		return
+	}
+	s.failures[request] = status
+}
+
+// RegistrationTokens returns the number of registration tokens created.
+func (s *Server) RegistrationTokens() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.tokens
+}
+
+// AddRunner adds a runner to the scope, assigning an ID if missing.
+func (s *Server) AddRunner(scope string, runner *github.Runner) *github.Runner {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.addRunner(scope, runner)
+}
+
+func (s *Server) addRunner(scope string, runner *github.Runner) *github.Runner {
+	if runner.ID == nil {
+		runner.ID = github.Int64(s.newID())
+	}
+	if runner.Status == nil {
+		runner.Status = github.String("online")
+	}
+	if runner.Busy == nil {
+		runner.Busy = github.Bool(false)
+	}
+	if s.runners[scope] == nil {
+		s.runners[scope] = make(map[int64]*github.Runner)
+	}
+	s.runners[scope][runner.GetID()] = runner
+	return runner
+}
+
+// UpdateRunner updates a runner in the scope; it returns false if not found.
+func (s *Server) UpdateRunner(scope string, id int64, update func(r *github.Runner)) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	runner, ok := s.runners[scope][id]
+	if ok {
+		update(runner)
+	}
+	return ok
+}
+
+// Runners returns runners of the scope ordered by ID.
+func (s *Server) Runners(scope string) []*github.Runner {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.sortedRunners(scope)
+}
+
+// AddRunnerGroup adds a runner group to the scope, assigning an ID if missing.
+func (s *Server) AddRunnerGroup(scope string, group *github.RunnerGroup) *github.RunnerGroup {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if group.ID == nil {
+		group.ID = github.Int64(s.newID())
+	}
+	s.groups[scope] = append(s.groups[scope], group)
+	return group
+}
+
+// SetRun adds or replaces an attempt of workflow run, assigning an ID and
+// the first attempt if missing.
+func (s *Server) SetRun(owner string, repo string, run *github.WorkflowRun) *github.WorkflowRun {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if run.ID == nil {
+		run.ID = github.Int64(s.newID())
+	}
+	if run.RunAttempt == nil {
+		run.RunAttempt = github.Int(1)
+	}
+	key := repoKey{Owner: owner, Repo: repo, ID: run.GetID()}
+	if s.runs[key] == nil {
+		s.runs[key] = make(map[int]*github.WorkflowRun)
+	}
+	s.runs[key][run.GetRunAttempt()] = run
+	return run
+}
+
+// SetJob adds or replaces a workflow job of latest run attempt, assigning an
+// ID if missing.
+func (s *Server) SetJob(owner string, repo string, job *github.WorkflowJob) *github.WorkflowJob {
+	s.lock.Lock()
+	attempt := s.latestAttempt(repoKey{Owner: owner, Repo: repo, ID: job.GetRunID()})
+	s.lock.Unlock()
+
+	return s.SetJobAttempt(owner, repo, attempt, job)
+}
+
+// SetJobAttempt adds or replaces a workflow job of a run attempt, assigning
+// an ID if missing.
+func (s *Server) SetJobAttempt(owner string, repo string, attempt int, job *github.WorkflowJob) *github.WorkflowJob {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if job.ID == nil {
+		job.ID = github.Int64(s.newID())
+	}
+	s.jobs[repoKey{Owner: owner, Repo: repo, ID: job.GetID()}] = &workflowJob{
+		WorkflowJob: job,
+		RunAttempt:  attempt,
+	}
+	return job
+}
+
+// SetRunUsage sets the usage of a workflow run.
+func (s *Server) SetRunUsage(owner string, repo string, runID int64, usage *github.WorkflowRunUsage) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.usages[repoKey{Owner: owner, Repo: repo, ID: runID}] = usage
+}
+
+// AddHookDelivery adds a delivery of the webhook, assigning an ID if missing.
+// The hook is the path prefix of webhook API: "repos/<owner>/<repo>/hooks/<id>",
+// "orgs/<org>/hooks/<id>" or "app/hook".
+func (s *Server) AddHookDelivery(hook string, delivery *github.HookDelivery) *github.HookDelivery {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if delivery.ID == nil {
+		delivery.ID = github.Int64(s.newID())
+	}
+	s.deliveries[hook] = append(s.deliveries[hook], delivery)
+	return delivery
+}
+
+// Redeliveries returns IDs of deliveries of the webhook requested to redeliver.
+func (s *Server) Redeliveries(hook string) []int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]int64(nil), s.redeliveries[hook]...)
+}
+
+func (s *Server) latestAttempt(key repoKey) int {
+	latest := 1
+	for attempt := range s.runs[key] {
+		if attempt > latest {
+			latest = attempt
+		}
+	}
+	return latest
+}
+
+// requestAttempt returns run attempt in request path, or the latest attempt.
+func (s *Server) requestAttempt(r *http.Request, key repoKey) int {
+	if attempt, err := strconv.Atoi(mux.Vars(r)["attempt"]); err == nil {
+		return attempt
+	}
+	return s.latestAttempt(key)
+}
+
+func (s *Server) newID() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// sortedRunners returns copies of runners of the scope ordered by ID, so
+// that they can be used after the lock is released.
+func (s *Server) sortedRunners(scope string) []*github.Runner {
+	var runners []*github.Runner
+	for _, r := range s.runners[scope] {
+		runners = append(runners, copyRunner(r))
+	}
+	sort.Slice(runners, func(i, j int) bool { return runners[i].GetID() < runners[j].GetID() })
+	return runners
+}
+
+func copyRunner(r *github.Runner) *github.Runner {
+	runner := *r
+	runner.Labels = nil
+	for _, l := range r.Labels {
+		label := *l
+		runner.Labels = append(runner.Labels, &label)
+	}
+	return &runner
+}
+
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		request := r.Method + " " + strings.TrimPrefix(r.URL.Path, apiPrefix)
+		s.lock.Lock()
+		s.requests = append(s.requests, request)
+		s.used++
+		remaining := rateLimitQuota - s.used
+		failure := s.failures[request]
+		s.lock.Unlock()
+
+		reset := time.Now().Truncate(time.Hour).Add(time.Hour)
+		rw.Header().Set("X-RateLimit-Limit", strconv.Itoa(rateLimitQuota))
+		rw.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		rw.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		rw.Header().Set("X-RateLimit-Resource", "core")
+		if failure != 0 {
+			respond(rw, failure, map[string]string{"message": http.StatusText(failure)})
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+func scopeOf(r *http.Request) string {
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix+"/")
+	scope, _, _ := strings.Cut(path, "/actions/")
+	return scope
+}
+
+func respond(rw http.ResponseWriter, status int, body any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(body)
+}
+
+func notFound(rw http.ResponseWriter) {
+	respond(rw, http.StatusNotFound, map[string]string{"message": "Not Found"})
+}
+
+func pathID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id
+}
+
+// paginate returns range of items in the requested page, and sets the Link
+// header for next page.
+func paginate(rw http.ResponseWriter, r *http.Request, total int) (begin int, end int) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 {
+		perPage = 30
+	}
+
+	begin = (page - 1) * perPage
+	end = begin + perPage
+	if begin > total {
+		begin = total
+	}
+	if end > total {
+		end = total
+	}
+
+	if end < total {
+		next := *r.URL
+		q := next.Query()
+		q.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = q.Encode()
+		next.Scheme = "http"
+		next.Host = r.Host
+		rw.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	}
+	return begin, end
+}
+
+func (s *Server) listRunners(rw http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	runners := s.sortedRunners(scopeOf(r))
+	s.lock.Unlock()
+
+	begin, end := paginate(rw, r, len(runners))
+	respond(rw, http.StatusOK, &github.Runners{
+		TotalCount: len(runners),
+		Runners:    runners[begin:end],
+	})
+}
+
+func (s *Server) deleteRunner(rw http.ResponseWriter, r *http.Request) {
+	scope := scopeOf(r)
+	id := pathID(r)
+
+	s.lock.Lock()
+	_, ok := s.runners[scope][id]
+	delete(s.runners[scope], id)
+	s.lock.Unlock()
+
+	if !ok {
+		notFound(rw)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) createRegistrationToken(rw http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	s.tokens++
+	token := fmt.Sprintf("token-%d", s.tokens)
+	s.lock.Unlock()
+
+	respond(rw, http.StatusCreated, &github.RegistrationToken{
+		Token:     github.String(token),
+		ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+	})
+}
+
+func (s *Server) generateJITConfig(rw http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name          string   `json:"name"`
+		RunnerGroupID int64    `json:"runner_group_id"`
+		Labels        []string `json:"labels"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respond(rw, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+
+	var labels []*github.RunnerLabels
+	for _, l := range req.Labels {
+		labels = append(labels, &github.RunnerLabels{Name: github.String(l), Type: github.String("custom")})
+	}
+	s.lock.Lock()
+	runner := copyRunner(s.addRunner(scopeOf(r), &github.Runner{
+		Name:   github.String(req.Name),
+		Status: github.String("offline"),
+		Labels: labels,
+	}))
+	s.lock.Unlock()
+
+	config, _ := json.Marshal(map[string]any{"runner_id": runner.GetID(), "name": req.Name})
+	respond(rw, http.StatusCreated, map[string]any{
+		"runner":             runner,
+		"encoded_jit_config": base64.StdEncoding.EncodeToString(config),
+	})
+}
+
+func (s *Server) listRunnerGroups(rw http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	groups := append([]*github.RunnerGroup(nil), s.groups[scopeOf(r)]...)
+	s.lock.Unlock()
+
+	begin, end := paginate(rw, r, len(groups))
+	respond(rw, http.StatusOK, &github.RunnerGroups{
+		TotalCount:   len(groups),
+		RunnerGroups: groups[begin:end],
+	})
+}
+
+func (s *Server) repoKey(r *http.Request) repoKey {
+	vars := mux.Vars(r)
+	return repoKey{Owner: vars["owner"], Repo: vars["repo"], ID: pathID(r)}
+}
+
+// listOrgRepos lists repositories of the organization, i.e. those having runs.
+func (s *Server) listOrgRepos(rw http.ResponseWriter, r *http.Request) {
+	org := mux.Vars(r)["org"]
+
+	s.lock.Lock()
+	names := make(map[string]struct{})
+	for key := range s.runs {
+		if key.Owner == org {
+			names[key.Repo] = struct{}{}
+		}
+	}
+	s.lock.Unlock()
+
+	var repos []*github.Repository
+	for name := range names {
+		repos = append(repos, &github.Repository{
+			Name:  github.String(name),
+			Owner: &github.User{Login: github.String(org)},
+		})
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].GetName() < repos[j].GetName() })
+
+	begin, end := paginate(rw, r, len(repos))
+	respond(rw, http.StatusOK, repos[begin:end])
+}
+
+// listRuns lists latest attempts of runs in the repository, latest first.
+func (s *Server) listRuns(rw http.ResponseWriter, r *http.Request) {
+	owner, repo := mux.Vars(r)["owner"], mux.Vars(r)["repo"]
+
+	s.lock.Lock()
+	var runs []*github.WorkflowRun
+	for key := range s.runs {
+		if key.Owner == owner && key.Repo == repo {
+			runs = append(runs, s.runs[key][s.latestAttempt(key)])
+		}
+	}
+	s.lock.Unlock()
+
+	sort.Slice(runs, func(i, j int) bool { return runs[i].GetID() > runs[j].GetID() })
+
+	begin, end := paginate(rw, r, len(runs))
+	respond(rw, http.StatusOK, &github.WorkflowRuns{
+		TotalCount:   github.Int(len(runs)),
+		WorkflowRuns: runs[begin:end],
+	})
+}
+
+func (s *Server) getRun(rw http.ResponseWriter, r *http.Request) {
+	key := s.repoKey(r)
+
+	s.lock.Lock()
+	run, ok := s.runs[key][s.requestAttempt(r, key)]
+	s.lock.Unlock()
+
+	if !ok {
+		notFound(rw)
+		return
+	}
+	respond(rw, http.StatusOK, run)
+}
+
+func (s *Server) listRunJobs(rw http.ResponseWriter, r *http.Request) {
+	key := s.repoKey(r)
+
+	s.lock.Lock()
+	attempt := s.requestAttempt(r, key)
+	var jobs []*workflowJob
+	for k, j := range s.jobs {
+		if k.Owner == key.Owner && k.Repo == key.Repo && j.GetRunID() == key.ID && j.RunAttempt == attempt {
+			jobs = append(jobs, j)
+		}
+	}
+	s.lock.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].GetID() < jobs[j].GetID() })
+	begin, end := paginate(rw, r, len(jobs))
+	respond(rw, http.StatusOK, map[string]any{
+		"total_count": len(jobs),
+		"jobs":        jobs[begin:end],
+	})
+}
+
+func (s *Server) getJob(rw http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	job, ok := s.jobs[s.repoKey(r)]
+	s.lock.Unlock()
+
+	if !ok {
+		notFound(rw)
+		return
+	}
+	respond(rw, http.StatusOK, job)
+}
+
+func (s *Server) getRunUsage(rw http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	usage, ok := s.usages[s.repoKey(r)]
+	s.lock.Unlock()
+
+	if !ok {
+		notFound(rw)
+		return
+	}
+	respond(rw, http.StatusOK, usage)
+}
+
+func hookOf(r *http.Request) string {
+	path := strings.TrimPrefix(r.URL.Path, apiPrefix+"/")
+	hook, _, _ := strings.Cut(path, "/deliveries")
+	return hook
+}
+
+func (s *Server) listHookDeliveries(rw http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	deliveries := append([]*github.HookDelivery(nil), s.deliveries[hookOf(r)]...)
+	s.lock.Unlock()
+
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].GetDeliveredAt().After(deliveries[j].GetDeliveredAt().Time)
+	})
+
+	// Deliveries are paginated by cursor, which is the offset here.
+	begin, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 {
+		perPage = 30
+	}
+	if begin > len(deliveries) {
+		begin = len(deliveries)
+	}
+	end := begin + perPage
+	if end > len(deliveries) {
+		end = len(deliveries)
+	}
+
+	if end < len(deliveries) {
+		next := *r.URL
+		q := next.Query()
+		q.Set("cursor", strconv.Itoa(end))
+		next.RawQuery = q.Encode()
+		next.Scheme = "http"
+		next.Host = r.Host
+		rw.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	}
+	respond(rw, http.StatusOK, deliveries[begin:end])
+}
+
+func (s *Server) redeliverHookDelivery(rw http.ResponseWriter, r *http.Request) {
+	hook := hookOf(r)
+	id := pathID(r)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, d := range s.deliveries[hook] {
+		if d.GetID() == id {
+			s.redeliveries[hook] = append(s.redeliveries[hook], id)
+			respond(rw, http.StatusAccepted, map[string]any{})
+			return
+		}
+	}
+	notFound(rw)
+}
@@ -0,0 +1,62 @@
+package githubtest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SendWebhook delivers a webhook event signed with secret to url, as GitHub
+// does. It returns the delivery ID.
+func SendWebhook(ctx context.Context, url string, secret string, event string, payload any) (string, error) {
+	deliveryID := newDeliveryID()
+	if err := SendWebhookDelivery(ctx, url, secret, event, deliveryID, payload); err != nil {
+		return "", err
+	}
+	return deliveryID, nil
+}
+
+// SendWebhookDelivery delivers a webhook event with the delivery ID, e.g. to
+// simulate redeliveries.
+func SendWebhookDelivery(ctx context.Context, url string, secret string, event string, deliveryID string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	r, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-GitHub-Event", event)
+	r.Header.Set("X-GitHub-Delivery", deliveryID)
+	r.Header.Set("X-Hub-Signature-256", signature)
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
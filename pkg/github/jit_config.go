@@ -0,0 +1,96 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v45/github"
+)
+
+const defaultRunnerGroupID = 1
+
+type JITConfig struct {
+	Runner           *github.Runner `json:"runner"`
+	EncodedJITConfig string         `json:"encoded_jit_config"`
+}
+
+type jitConfigRequest struct {
+	Name          string   `json:"name"`
+	RunnerGroupID int64    `json:"runner_group_id"`
+	Labels        []string `json:"labels"`
+	WorkFolder    string   `json:"work_folder,omitempty"`
+}
+
+var runnerOSLabels = map[string]string{
+	"linux":   "Linux",
+	"windows": "Windows",
+	"darwin":  "macOS",
+}
+
+var runnerArchLabels = map[string]string{
+	"amd64": "X64",
+	"386":   "X86",
+	"arm64": "ARM64",
+	"arm":   "ARM",
+}
+
+// RunnerDefaultLabels returns the OS and architecture labels that config.sh
+// would add to a runner, given GOOS and GOARCH of the runner host.
+func RunnerDefaultLabels(goos string, goarch string) []string {
+	var labels []string
+	if l, ok := runnerOSLabels[goos]; ok {
+		labels = append(labels, l)
+	}
+	if l, ok := runnerArchLabels[goarch]; ok {
+		labels = append(labels, l)
+	}
+	return labels
+}
+
+func newJITConfigRequest(name string, groupID int64, labels []string, workFolder string) *jitConfigRequest {
+	// Unlike config.sh, default labels are not added to JIT runners; OS and
+	// architecture labels are expected to be provided by caller.
+	runnerLabels := []string{"self-hosted"}
+	seen := map[string]bool{"self-hosted": true}
+	for _, l := range labels {
+		if l != "" && !seen[strings.ToLower(l)] {
+			seen[strings.ToLower(l)] = true
+			runnerLabels = append(runnerLabels, l)
+		}
+	}
+
+	return &jitConfigRequest{
+		Name:          name,
+		RunnerGroupID: groupID,
+		Labels:        runnerLabels,
+		WorkFolder:    workFolder,
+	}
+}
+
+func generateJITConfig(ctx context.Context, client *github.Client, urlPath string, req *jitConfigRequest) (*JITConfig, error) {
+	ctx = WithAdminCredential(ctx)
+	r, err := client.NewRequest("POST", urlPath, req)
+	if err != nil {
+		return nil, err
+	}
+
+	config := new(JITConfig)
+	if _, err := client.Do(ctx, r, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func findRunnerGroupID(groups []*github.RunnerGroup, name string) (int64, error) {
+	if name == "" {
+		return defaultRunnerGroupID, nil
+	}
+	for _, g := range groups {
+		if g.GetName() == name {
+			return g.GetID(), nil
+		}
+	}
+	return 0, fmt.Errorf("runner group not found: %s", name)
+}
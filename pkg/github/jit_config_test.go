@@ -0,0 +1,22 @@
+package github
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJITConfigRequest(t *testing.T) {
+	Convey("JIT config requests", t, func() {
+		Convey("Include self-hosted and deduplicated labels", func() {
+			labels := append(RunnerDefaultLabels("linux", "amd64"), "gpu", "linux", "", "self-hosted")
+			req := newJITConfigRequest("runner", 1, labels, "")
+			So(req.Labels, ShouldResemble, []string{"self-hosted", "Linux", "X64", "gpu"})
+		})
+
+		Convey("Skip unknown OS and architecture", func() {
+			So(RunnerDefaultLabels("darwin", "arm64"), ShouldResemble, []string{"macOS", "ARM64"})
+			So(RunnerDefaultLabels("plan9", "mips"), ShouldBeEmpty)
+		})
+	})
+}
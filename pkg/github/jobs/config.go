@@ -4,17 +4,85 @@ import (
 	"time"
 
 	"github.com/oursky/github-actions-manager/pkg/utils/defaults"
+	"github.com/oursky/github-actions-manager/pkg/utils/promutil"
 )
 
 const KVKey = "jobs"
 
 type Config struct {
-	Disabled          bool
-	RetentionPeriod   *time.Duration
-	SyncInterval      *time.Duration
-	SyncPageSize      *int    `validate:"omitempty,min=1,max=100"`
+	Disabled        bool
+	RetentionPeriod *time.Duration
+	SyncInterval    *time.Duration
+	SyncPageSize    *int `validate:"omitempty,min=1,max=100"`
+	// SaveInterval is the interval changed state is saved to store.
+	SaveInterval *time.Duration
+	// RefreshConcurrency is the number of concurrent requests refreshing
+	// incomplete runs and jobs.
+	RefreshConcurrency *int `validate:"omitempty,min=1"`
+	// RefreshBudget is the number of requests refreshing incomplete runs and
+	// jobs per sync interval, when API quota is sufficient.
+	RefreshBudget     *int    `validate:"omitempty,min=1"`
 	WebhookServerAddr *string `validate:"omitempty,tcp_addr"`
 	WebhookSecret     string  `validate:"required_if=Disabled false"`
+	// WebhookQueueSize is the number of received webhooks waiting to be
+	// processed; deliveries are rejected when the queue is full.
+	WebhookQueueSize *int `validate:"omitempty,min=1"`
+	// RunLookupConcurrency is the number of concurrent requests looking up
+	// runs of jobs received from webhook.
+	RunLookupConcurrency *int                 `validate:"omitempty,min=1"`
+	MetricsMode          promutil.MetricsMode `validate:"omitempty,oneof=Detailed Aggregated All"`
+	// DeliveryDedupWindow is the duration processed webhook deliveries are
+	// remembered, to ignore duplicated deliveries.
+	DeliveryDedupWindow *time.Duration
+	// Hook is the webhook sending events to the synchronizer; deliveries
+	// failed while not running are redelivered on startup.
+	Hook *HookConfig
+	// Journal keeps received webhook deliveries on disk, if set.
+	Journal *JournalConfig
+	// Polling discovers runs by listing recent runs of repositories, if set.
+	Polling *PollingConfig
+}
+
+type PollingConfig struct {
+	Interval *time.Duration
+	// Repositories are polled repositories, in "owner/name" format.
+	Repositories []string `validate:"dive,required,contains=/"`
+	// Organizations are organizations of which all repositories are polled.
+	Organizations []string `validate:"dive,required"`
+}
+
+func (c *PollingConfig) GetInterval() time.Duration {
+	return defaults.Value(c.Interval, 1*time.Minute)
+}
+
+type JournalConfig struct {
+	Dir         string `validate:"required"`
+	MaxFileSize *int64 `validate:"omitempty,min=1"`
+	MaxFiles    *int   `validate:"omitempty,min=1"`
+}
+
+func (c *JournalConfig) GetMaxFileSize() int64 {
+	return defaults.Value(c.MaxFileSize, 64*1024*1024)
+}
+
+func (c *JournalConfig) GetMaxFiles() int {
+	return defaults.Value(c.MaxFiles, 10)
+}
+
+type HookType string
+
+const (
+	HookTypeRepository   HookType = "Repository"
+	HookTypeOrganization HookType = "Organization"
+	HookTypeApp          HookType = "App"
+)
+
+type HookConfig struct {
+	Type HookType `validate:"required,oneof=Repository Organization App"`
+	// Owner is the organization or repository owner of the webhook.
+	Owner string `validate:"required_unless=Type App"`
+	Repo  string `validate:"required_if=Type Repository"`
+	ID    int64  `validate:"required_unless=Type App"`
 }
 
 func (c *Config) GetRetentionPeriod() time.Duration {
@@ -25,10 +93,34 @@ func (c *Config) GetSyncInterval() time.Duration {
 	return defaults.Value(c.SyncInterval, 10*time.Second)
 }
 
+func (c *Config) GetSaveInterval() time.Duration {
+	return defaults.Value(c.SaveInterval, 10*time.Second)
+}
+
 func (c *Config) GetSyncPageSize() int {
 	return defaults.Value(c.SyncPageSize, 30)
 }
 
+func (c *Config) GetWebhookQueueSize() int {
+	return defaults.Value(c.WebhookQueueSize, 1000)
+}
+
+func (c *Config) GetRunLookupConcurrency() int {
+	return defaults.Value(c.RunLookupConcurrency, 4)
+}
+
+func (c *Config) GetRefreshConcurrency() int {
+	return defaults.Value(c.RefreshConcurrency, 4)
+}
+
+func (c *Config) GetRefreshBudget() int {
+	return defaults.Value(c.RefreshBudget, 10)
+}
+
+func (c *Config) GetDeliveryDedupWindow() time.Duration {
+	return defaults.Value(c.DeliveryDedupWindow, 1*time.Hour)
+}
+
 func (c *Config) GetWebhookServerAddr() string {
 	return defaults.Value(c.WebhookServerAddr, "127.0.0.1:8001")
 }
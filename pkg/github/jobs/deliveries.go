@@ -0,0 +1,307 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	gh "github.com/oursky/github-actions-manager/pkg/github"
+	"github.com/oursky/github-actions-manager/pkg/github/auth"
+
+	"github.com/google/go-github/v45/github"
+	"go.uber.org/zap"
+)
+
+// maxTrackedDeliveries bounds memory used by delivery tracker regardless of
+// dedup window.
+const maxTrackedDeliveries = 10000
+
+// deliveryRetention is the duration GitHub keeps webhook deliveries.
+const deliveryRetention = 3 * 24 * time.Hour
+
+// deliveryRecoveryMargin covers deliveries failed shortly before the time all
+// received deliveries were processed, e.g. rejected when queue was full.
+const deliveryRecoveryMargin = 5 * time.Minute
+
+// deliveryClockSkew covers difference of delivery times recorded by GitHub
+// and receive times recorded by synchronizer.
+const deliveryClockSkew = 1 * time.Minute
+
+// deliveryTracker remembers recently processed webhook deliveries by their
+// delivery ID, to ignore duplicated deliveries and redeliveries.
+type deliveryTracker struct {
+	lock   sync.Mutex
+	window time.Duration
+	seen   map[string]time.Time
+	order  []trackedDelivery
+	// pending counts deliveries queued but not yet processed by their
+	// receive times.
+	pending map[time.Time]int
+	last    time.Time
+}
+
+type trackedDelivery struct {
+	id string
+	at time.Time
+}
+
+func newDeliveryTracker(window time.Duration) *deliveryTracker {
+	return &deliveryTracker{
+		window:  window,
+		seen:    make(map[string]time.Time),
+		pending: make(map[time.Time]int),
+	}
+}
+
+// begin claims the delivery for processing; it returns false if the delivery
+// was already processed or being processed.
+func (t *deliveryTracker) begin(id string, now time.Time) bool {
+	if id == "" {
+		return true
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.prune(now)
+	if _, ok := t.seen[id]; ok {
+		return false
+	}
+	t.seen[id] = now
+	t.order = append(t.order, trackedDelivery{id: id, at: now})
+	return true
+}
+
+// forget releases a delivery failed to process, so that it can be redelivered.
+func (t *deliveryTracker) forget(id string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.seen, id)
+}
+
+// queue records a delivery received at the time is queued for processing.
+func (t *deliveryTracker) queue(receivedAt time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.pending[receivedAt]++
+}
+
+// discard releases a queued delivery not to be processed.
+func (t *deliveryTracker) discard(receivedAt time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.release(receivedAt)
+}
+
+// finish records a queued delivery received at the time is processed.
+func (t *deliveryTracker) finish(receivedAt time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.release(receivedAt)
+	if receivedAt.After(t.last) {
+		t.last = receivedAt
+	}
+}
+
+func (t *deliveryTracker) release(receivedAt time.Time) {
+	if t.pending[receivedAt] <= 1 {
+		delete(t.pending, receivedAt)
+	} else {
+		t.pending[receivedAt]--
+	}
+}
+
+// restore records time all deliveries were processed before, as saved before
+// restart.
+func (t *deliveryTracker) restore(at time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if at.After(t.last) {
+		t.last = at
+	}
+}
+
+func (t *deliveryTracker) has(id string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	_, ok := t.seen[id]
+	return ok
+}
+
+// lastProcessed returns time before which all received deliveries are
+// processed: receive time of earliest pending delivery, or of last processed
+// delivery if none is pending.
+func (t *deliveryTracker) lastProcessed() time.Time {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	last := t.last
+	first := true
+	for at := range t.pending {
+		if first || at.Before(last) {
+			last = at
+			first = false
+		}
+	}
+	return last
+}
+
+func (t *deliveryTracker) prune(now time.Time) {
+	limit := now.Add(-t.window)
+	for len(t.order) > 0 {
+		d := t.order[0]
+		if d.at.After(limit) && len(t.order) <= maxTrackedDeliveries {
+			break
+		}
+		// The delivery may be forgotten and claimed again later.
+		if seenAt, ok := t.seen[d.id]; ok && seenAt.Equal(d.at) {
+			delete(t.seen, d.id)
+		}
+		t.order = t.order[1:]
+	}
+}
+
+// recoverDeliveries redelivers workflow webhook deliveries that may be lost
+// since all received deliveries were processed: deliveries failed, e.g. while
+// synchronizer was not running, and deliveries acknowledged but not processed
+// before shutdown.
+func (s *Synchronizer) recoverDeliveries(ctx context.Context, since time.Time) {
+	hook := s.config.Hook
+	if hook == nil || since.IsZero() {
+		return
+	}
+
+	cutoff := since.Add(-deliveryRecoveryMargin)
+	if limit := time.Now().Add(-deliveryRetention); cutoff.Before(limit) {
+		cutoff = limit
+	}
+
+	deliveries, err := s.listHookDeliveries(ctx, hook, cutoff)
+	if err != nil {
+		s.logger.Warn("failed to list webhook deliveries", zap.Error(err))
+		return
+	}
+
+	// Deliveries acknowledged after this time may be queued but not
+	// processed when stopped.
+	processedBefore := since.Add(-deliveryClockSkew)
+
+	succeeded := make(map[string]bool)
+	latest := make(map[string]*github.HookDelivery)
+	for _, d := range deliveries {
+		if d.GetEvent() != "workflow_job" && d.GetEvent() != "workflow_run" {
+			continue
+		}
+		guid := d.GetGUID()
+		code := d.GetStatusCode()
+		if code >= 200 && code < 300 && d.GetDeliveredAt().Before(processedBefore) {
+			succeeded[guid] = true
+			continue
+		}
+		if l, ok := latest[guid]; !ok || d.GetDeliveredAt().After(l.GetDeliveredAt().Time) {
+			latest[guid] = d
+		}
+	}
+
+	var missed []*github.HookDelivery
+	for guid, d := range latest {
+		if succeeded[guid] || s.server.deliveries.has(guid) {
+			continue
+		}
+		missed = append(missed, d)
+	}
+	sort.Slice(missed, func(i, j int) bool {
+		return missed[i].GetDeliveredAt().Before(missed[j].GetDeliveredAt().Time)
+	})
+
+	redelivered := 0
+	for _, d := range missed {
+		if err := s.redeliverHookDelivery(ctx, hook, d.GetID()); err != nil {
+			s.logger.Warn("failed to redeliver webhook",
+				zap.Error(err),
+				zap.String("id", d.GetGUID()),
+				zap.String("event", d.GetEvent()),
+			)
+			continue
+		}
+		redelivered++
+	}
+
+	s.logger.Info("recovered webhook deliveries",
+		zap.Time("since", cutoff),
+		zap.Int("deliveries", len(deliveries)),
+		zap.Int("redelivered", redelivered),
+	)
+}
+
+// listHookDeliveries lists deliveries of the hook delivered after cutoff.
+func (s *Synchronizer) listHookDeliveries(ctx context.Context, hook *HookConfig, cutoff time.Time) ([]*github.HookDelivery, error) {
+	opts := &github.ListCursorOptions{PerPage: 100}
+
+	var deliveries []*github.HookDelivery
+	for {
+		var page []*github.HookDelivery
+		var resp *github.Response
+		var err error
+		switch hook.Type {
+		case HookTypeRepository:
+			page, resp, err = s.github.Repositories.ListHookDeliveries(ctx, hook.Owner, hook.Repo, hook.ID, opts)
+		case HookTypeOrganization:
+			page, resp, err = s.github.Organizations.ListHookDeliveries(ctx, hook.Owner, hook.ID, opts)
+		case HookTypeApp:
+			page, resp, err = s.github.Apps.ListHookDeliveries(appHookContext(ctx), opts)
+		default:
+			return nil, fmt.Errorf("unknown hook type: %s", hook.Type)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Deliveries are listed from newest to oldest.
+		for _, d := range page {
+			if d.GetDeliveredAt().Before(cutoff) {
+				return deliveries, nil
+			}
+			deliveries = append(deliveries, d)
+		}
+
+		if resp.Cursor == "" {
+			return deliveries, nil
+		}
+		opts.Cursor = resp.Cursor
+	}
+}
+
+func (s *Synchronizer) redeliverHookDelivery(ctx context.Context, hook *HookConfig, id int64) error {
+	var err error
+	switch hook.Type {
+	case HookTypeRepository:
+		_, _, err = s.github.Repositories.RedeliverHookDelivery(ctx, hook.Owner, hook.Repo, hook.ID, id)
+	case HookTypeOrganization:
+		_, _, err = s.github.Organizations.RedeliverHookDelivery(ctx, hook.Owner, hook.ID, id)
+	case HookTypeApp:
+		_, _, err = s.github.Apps.RedeliverHookDelivery(appHookContext(ctx), id)
+	default:
+		err = fmt.Errorf("unknown hook type: %s", hook.Type)
+	}
+
+	// Redeliveries are accepted asynchronously.
+	if _, ok := err.(*github.AcceptedError); ok {
+		return nil
+	}
+	return err
+}
+
+// appHookContext authenticates as the app itself, as required by app
+// webhook APIs.
+func appHookContext(ctx context.Context) context.Context {
+	return auth.WithAppAuth(gh.WithAdminCredential(ctx))
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDeliveryTracker(t *testing.T) {
+	Convey("Deliveries are processed before earliest pending delivery", t, func() {
+		tracker := newDeliveryTracker(time.Hour)
+		now := time.Now()
+		at := func(s int) time.Time { return now.Add(time.Duration(s) * time.Second) }
+
+		tracker.restore(at(0))
+		So(tracker.lastProcessed(), ShouldEqual, at(0))
+
+		tracker.queue(at(1))
+		tracker.queue(at(2))
+		tracker.queue(at(3))
+		So(tracker.lastProcessed(), ShouldEqual, at(1))
+
+		// Deliveries may be processed out of order, e.g. runs and jobs.
+		tracker.finish(at(2))
+		So(tracker.lastProcessed(), ShouldEqual, at(1))
+
+		tracker.discard(at(1))
+		So(tracker.lastProcessed(), ShouldEqual, at(3))
+
+		tracker.finish(at(3))
+		So(tracker.lastProcessed(), ShouldEqual, at(3))
+		tracker.queue(at(4))
+		So(tracker.lastProcessed(), ShouldEqual, at(4))
+	})
+}
@@ -0,0 +1,310 @@
+package jobs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	journalFile       = "journal.jsonl"
+	journalFilePrefix = "journal-"
+	journalTimeFormat = "20060102T150405.000000000"
+)
+
+// journalHeaders are request headers kept in journal.
+var journalHeaders = []string{
+	"Content-Type",
+	"User-Agent",
+	"X-GitHub-Event",
+	"X-GitHub-Delivery",
+	"X-GitHub-Hook-ID",
+	"X-GitHub-Hook-Installation-Target-ID",
+	"X-GitHub-Hook-Installation-Target-Type",
+}
+
+// JournalEntry is a validated webhook delivery kept in journal.
+type JournalEntry struct {
+	ReceivedAt time.Time         `json:"receivedAt"`
+	Headers    map[string]string `json:"headers"`
+	Payload    json.RawMessage   `json:"payload"`
+}
+
+// journal appends webhook deliveries to files in a directory, rotating files
+// exceeding size limit.
+type journal struct {
+	dir      string
+	maxSize  int64
+	maxFiles int
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+func newJournal(config *JournalConfig) *journal {
+	return &journal{
+		dir:      config.Dir,
+		maxSize:  config.GetMaxFileSize(),
+		maxFiles: config.GetMaxFiles(),
+	}
+}
+
+func (j *journal) append(r *http.Request, payload []byte, now time.Time) error {
+	if !json.Valid(payload) {
+		return fmt.Errorf("payload is not JSON")
+	}
+
+	entry := JournalEntry{
+		ReceivedAt: now,
+		Headers:    make(map[string]string),
+		Payload:    payload,
+	}
+	for _, h := range journalHeaders {
+		if v := r.Header.Get(h); v != "" {
+			entry.Headers[h] = v
+		}
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.file != nil && j.size+int64(len(data)) > j.maxSize {
+		if err := j.rotate(now); err != nil {
+			return err
+		}
+	}
+	if j.file == nil {
+		if err := j.open(); err != nil {
+			return err
+		}
+	}
+
+	n, err := j.file.Write(data)
+	j.size += int64(n)
+	return err
+}
+
+func (j *journal) open() error {
+	if err := os.MkdirAll(j.dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(j.dir, journalFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	j.file = file
+	j.size = info.Size()
+	return nil
+}
+
+// rotate renames current file by time, and deletes oldest files exceeding
+// file limit.
+func (j *journal) rotate(now time.Time) error {
+	j.file.Close()
+	j.file = nil
+
+	rotated := journalFilePrefix + now.UTC().Format(journalTimeFormat) + ".jsonl"
+	if err := os.Rename(filepath.Join(j.dir, journalFile), filepath.Join(j.dir, rotated)); err != nil {
+		return err
+	}
+
+	files, err := journalFiles(j.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > j.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+func (j *journal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// journalFiles lists journal files in directory, oldest first.
+func journalFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var rotated []string
+	current := ""
+	for _, e := range entries {
+		switch name := e.Name(); {
+		case name == journalFile:
+			current = filepath.Join(dir, name)
+		case strings.HasPrefix(name, journalFilePrefix) && strings.HasSuffix(name, ".jsonl"):
+			rotated = append(rotated, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(rotated)
+	if current != "" {
+		rotated = append(rotated, current)
+	}
+	return rotated, nil
+}
+
+// ReadJournal reads deliveries in a journal file, or all journal files in a
+// directory, oldest first.
+func ReadJournal(path string, fn func(e *JournalEntry) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files, err = journalFiles(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range files {
+		if err := readJournalFile(name, fn); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func readJournalFile(name string, fn func(e *JournalEntry) error) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 32*1024*1024)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return err
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+const (
+	replayRetryBackoff    = 100 * time.Millisecond
+	replayMaxRetryBackoff = 5 * time.Second
+	replayMaxAttempts     = 20
+)
+
+// ReplayResult counts replayed journal entries by result.
+type ReplayResult struct {
+	// Replayed is the number of deliveries accepted by webhook server.
+	Replayed int
+	// Duplicated is the number of deliveries ignored as already processed.
+	Duplicated int
+	// Skipped is the number of deliveries rejected by webhook server.
+	Skipped int
+}
+
+// ReplayJournal delivers webhooks in journal to webhook server at url,
+// signing them with secret. Deliveries rejected as webhook queue is full are
+// retried with backoff.
+func ReplayJournal(ctx context.Context, path string, url string, secret string) (ReplayResult, error) {
+	var result ReplayResult
+	err := ReadJournal(path, func(e *JournalEntry) error {
+		backoff := replayRetryBackoff
+		for attempt := 1; ; attempt++ {
+			status, body, err := replayEntry(ctx, url, secret, e)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case status == http.StatusServiceUnavailable && attempt < replayMaxAttempts:
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+				if backoff > replayMaxRetryBackoff {
+					backoff = replayMaxRetryBackoff
+				}
+				continue
+			case status == http.StatusServiceUnavailable:
+				return fmt.Errorf("delivery %s: webhook server is unavailable", e.Headers["X-GitHub-Delivery"])
+			case status < 200 || status >= 300:
+				result.Skipped++
+			case body == duplicatedDeliveryResponse:
+				result.Duplicated++
+			default:
+				result.Replayed++
+			}
+			return nil
+		}
+	})
+	return result, err
+}
+
+func replayEntry(ctx context.Context, url string, secret string, e *JournalEntry) (int, string, error) {
+	r, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(e.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	for h, v := range e.Headers {
+		r.Header.Set(h, v)
+	}
+	// Payloads are kept as JSON, even if originally form-encoded.
+	r.Header.Set("Content-Type", "application/json")
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(e.Payload)
+	r.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return 0, "", err
+	}
+	return resp.StatusCode, string(body), nil
+}
@@ -0,0 +1,160 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/github/githubtest"
+	"github.com/oursky/github-actions-manager/pkg/kv"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/prometheus/client_golang/prometheus"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestJournal(t *testing.T) {
+	Convey("Journal files are rotated by size", t, func() {
+		dir := t.TempDir()
+		maxSize := int64(256)
+		maxFiles := 2
+		j := newJournal(&JournalConfig{Dir: dir, MaxFileSize: &maxSize, MaxFiles: &maxFiles})
+		defer j.Close()
+
+		now := time.Now()
+		for i := 0; i < 20; i++ {
+			r, _ := http.NewRequest("POST", "/", nil)
+			r.Header.Set("X-GitHub-Event", "ping")
+			r.Header.Set("X-GitHub-Delivery", fmt.Sprintf("delivery-%d", i))
+			err := j.append(r, []byte(fmt.Sprintf(`{"zen": "%d"}`, i)), now.Add(time.Duration(i)*time.Second))
+			So(err, ShouldBeNil)
+		}
+
+		files, err := journalFiles(dir)
+		So(err, ShouldBeNil)
+		So(files, ShouldHaveLength, maxFiles+1)
+
+		var ids []string
+		err = ReadJournal(dir, func(e *JournalEntry) error {
+			ids = append(ids, e.Headers["X-GitHub-Delivery"])
+			So(e.Headers["X-GitHub-Event"], ShouldEqual, "ping")
+			return nil
+		})
+		So(err, ShouldBeNil)
+		So(ids, ShouldNotBeEmpty)
+		So(ids[len(ids)-1], ShouldEqual, "delivery-19")
+		for i := 1; i < len(ids); i++ {
+			So(ids[i], ShouldEqual, fmt.Sprintf("delivery-%d", 20-len(ids)+i))
+		}
+	})
+
+	Convey("Journal of webhooks can be replayed to rebuild state", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		g, ctx := errgroup.WithContext(ctx)
+
+		server := githubtest.NewServer()
+		defer server.Close()
+
+		dir := t.TempDir()
+		secret := "secret"
+		newSync := func(journal *JournalConfig) (*Synchronizer, string) {
+			addr := githubtest.FreeAddr()
+			sync, err := NewSynchronizer(
+				zap.NewNop(),
+				&Config{WebhookServerAddr: &addr, WebhookSecret: secret, Journal: journal},
+				server.Client(),
+				kv.NewInMemoryStore(),
+				prometheus.NewPedanticRegistry(),
+			)
+			So(err, ShouldBeNil)
+			So(sync.Start(ctx, g), ShouldBeNil)
+			return sync, "http://" + addr
+		}
+		runCount := func(sync *Synchronizer) int {
+			state := sync.State().Value()
+			if state == nil {
+				return 0
+			}
+			return len(state.WorkflowRuns)
+		}
+
+		journaled, url := newSync(&JournalConfig{Dir: dir})
+
+		now := time.Now()
+		run := server.SetRun("acme", "repo", &github.WorkflowRun{
+			Name:      github.String("CI"),
+			Status:    github.String("in_progress"),
+			UpdatedAt: &github.Timestamp{Time: now},
+		})
+		job := server.SetJob("acme", "repo", &github.WorkflowJob{
+			RunID:     run.ID,
+			Name:      github.String("build"),
+			Status:    github.String("in_progress"),
+			StartedAt: &github.Timestamp{Time: now},
+		})
+		err := sendWebhook(ctx, url, secret, "workflow_job", &github.WorkflowJobEvent{
+			Action:      github.String("in_progress"),
+			WorkflowJob: job,
+			Repo: &github.Repository{
+				Name:  github.String("repo"),
+				Owner: &github.User{Login: github.String("acme")},
+			},
+		})
+		So(err, ShouldBeNil)
+		ok := githubtest.Eventually(5*time.Second, func() bool { return runCount(journaled) == 1 })
+		So(ok, ShouldBeTrue)
+
+		replayed, url := newSync(nil)
+		var result ReplayResult
+		ok = githubtest.Eventually(5*time.Second, func() bool {
+			result, err = ReplayJournal(ctx, dir, url, secret)
+			return err == nil
+		})
+		So(ok, ShouldBeTrue)
+		So(result, ShouldResemble, ReplayResult{Replayed: 1})
+
+		ok = githubtest.Eventually(5*time.Second, func() bool { return runCount(replayed) == 1 })
+		So(ok, ShouldBeTrue)
+		So(replayed.State().Value().WorkflowRuns[0].Jobs[0].ID, ShouldEqual, job.GetID())
+
+		result, err = ReplayJournal(ctx, dir, url, secret)
+		So(err, ShouldBeNil)
+		So(result, ShouldResemble, ReplayResult{Duplicated: 1})
+	})
+
+	Convey("Replay retries deliveries while webhook queue is full", t, func() {
+		dir := t.TempDir()
+		j := newJournal(&JournalConfig{Dir: dir})
+		for _, id := range []string{"busy", "invalid", "ok"} {
+			r, _ := http.NewRequest("POST", "/", nil)
+			r.Header.Set("X-GitHub-Delivery", id)
+			So(j.append(r, []byte(`{}`), time.Now()), ShouldBeNil)
+		}
+		So(j.Close(), ShouldBeNil)
+
+		busy := 0
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			switch r.Header.Get("X-GitHub-Delivery") {
+			case "busy":
+				busy++
+				if busy < 3 {
+					rw.WriteHeader(http.StatusServiceUnavailable)
+				}
+			case "invalid":
+				rw.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+		defer server.Close()
+
+		result, err := ReplayJournal(context.Background(), dir, server.URL, "secret")
+		So(err, ShouldBeNil)
+		So(busy, ShouldEqual, 3)
+		So(result, ShouldResemble, ReplayResult{Replayed: 2, Skipped: 1})
+	})
+}
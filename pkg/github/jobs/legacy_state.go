@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// parseLegacyState parses list of run keys saved by previous versions.
+func (s *Synchronizer) parseLegacyState(data string) []Key {
+	var keys []Key
+	for _, k := range strings.Split(data, ";") {
+		parts := strings.Split(k, "/")
+		if len(parts) != 3 {
+			s.logger.Warn("failed to load state", zap.String("key", k))
+			continue
+		}
+
+		id, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			s.logger.Warn("failed to load state", zap.Error(err))
+			continue
+		}
+		keys = append(keys, Key{RepoOwner: parts[0], RepoName: parts[1], ID: id})
+	}
+	return keys
+}
+
+// loadLegacyState reloads state of runs saved by previous versions from
+// GitHub.
+func (s *Synchronizer) loadLegacyState(ctx context.Context, st workState, keys []Key) {
+	for _, key := range keys {
+		wrun, err := getWorkflowRun(ctx, s.github, key.RepoOwner, key.RepoName, key.ID, 0)
+		if err != nil {
+			s.logger.Warn("failed to refresh state", zap.Error(err), zap.Int64("id", key.ID))
+			continue
+		}
+		st.setRun(key.RepoOwner, key.RepoName, wrun, true)
+
+		wjobs, err := listWorkflowJobs(ctx, s.github, key.RepoOwner, key.RepoName, key.ID, runAttempt(wrun))
+		if err != nil {
+			s.logger.Warn("failed to refresh state", zap.Error(err), zap.Int64("id", key.ID))
+			continue
+		}
+		for _, job := range wjobs {
+			st.setJob(key.RepoOwner, key.RepoName, job, true)
+		}
+	}
+
+	s.logger.Info("reloaded state", zap.Int("runs", len(st.runs)), zap.Int("jobs", len(st.jobs)))
+}
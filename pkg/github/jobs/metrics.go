@@ -2,12 +2,14 @@ package jobs
 
 import (
 	"sync"
+	"time"
 
 	"github.com/oursky/github-actions-manager/pkg/utils/promutil"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type metrics struct {
+	mode  promutil.MetricsMode
 	state *State
 	lock  *sync.RWMutex
 
@@ -16,10 +18,32 @@ type metrics struct {
 	statusCompleted  *promutil.MetricDesc
 	startedAt        *promutil.MetricDesc
 	completedAt      *promutil.MetricDesc
+	jobsQueued       *promutil.MetricDesc
+	jobsInProgress   *promutil.MetricDesc
+	stateSize        *promutil.MetricDesc
+	refreshQueue     *promutil.MetricDesc
+	refreshStaleness *promutil.MetricDesc
+
+	queueWait      *prometheus.HistogramVec
+	execution      *prometheus.HistogramVec
+	refreshedAfter prometheus.Histogram
+
+	stateSizeBytes      int
+	refreshQueueDepth   int
+	refreshMaxStaleness time.Duration
 }
 
-func newMetrics(r *prometheus.Registry) *metrics {
+var transitionLabels = []string{
+	"repository_owner",
+	"repository_name",
+	"workflow_name",
+	"workflow_job_name",
+	"label_set",
+}
+
+func newMetrics(mode promutil.MetricsMode, r *prometheus.Registry) *metrics {
 	m := &metrics{
+		mode:  mode,
 		state: nil,
 		lock:  new(sync.RWMutex),
 
@@ -53,19 +77,83 @@ func newMetrics(r *prometheus.Registry) *metrics {
 			Name:      "completion_time",
 			Help:      "Completion time in unix timestamp for a job.",
 		}),
+		jobsQueued: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "jobs",
+			Name:      "queued",
+			Help:      "Number of queued jobs.",
+		}),
+		jobsInProgress: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "jobs",
+			Name:      "in_progress",
+			Help:      "Number of jobs in progress.",
+		}),
+		stateSize: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "jobs",
+			Name:      "state_size_bytes",
+			Help:      "Size of last saved job synchronizer state.",
+		}),
+		refreshQueue: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "jobs",
+			Name:      "refresh_queue_depth",
+			Help:      "Number of incomplete runs and jobs waiting to be refreshed.",
+		}),
+		refreshStaleness: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "jobs",
+			Name:      "refresh_max_staleness_seconds",
+			Help:      "Time since the stalest incomplete run or job is last received.",
+		}),
+		queueWait: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "github_actions",
+			Subsystem: "job",
+			Name:      "queue_wait_seconds",
+			Help:      "Time from job creation to start.",
+			Buckets:   []float64{5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200, 21600},
+		}, transitionLabels),
+		execution: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "github_actions",
+			Subsystem: "job",
+			Name:      "execution_seconds",
+			Help:      "Time from job start to completion.",
+			Buckets:   []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200, 21600},
+		}, transitionLabels),
+		refreshedAfter: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "github_actions",
+			Subsystem: "jobs",
+			Name:      "refresh_staleness_seconds",
+			Help:      "Time since incomplete runs and jobs are last received, when refreshed.",
+			Buckets:   []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+		}),
 	}
-	r.MustRegister(m)
+	r.MustRegister(m, m.queueWait, m.execution, m.refreshedAfter)
 	return m
 }
 
 func (m *metrics) Describe(ch chan<- *prometheus.Desc) {}
 
 func (m *metrics) Collect(ch chan<- prometheus.Metric) {
-	state := m.get()
+	state, stateSize := m.get()
+	if stateSize > 0 {
+		ch <- m.stateSize.Gauge(float64(stateSize), nil)
+	}
+	queueDepth, maxStaleness := m.getRefreshQueue()
+	ch <- m.refreshQueue.Gauge(float64(queueDepth), nil)
+	ch <- m.refreshStaleness.Gauge(maxStaleness.Seconds(), nil)
 	if state == nil {
 		return
 	}
 
+	if m.mode.Aggregated() {
+		m.collectAggregated(ch, state)
+	}
+	if !m.mode.Detailed() {
+		return
+	}
+
 	for _, run := range state.WorkflowRuns {
 		for _, job := range run.Jobs {
 			labels := job.labels()
@@ -87,11 +175,69 @@ func (m *metrics) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
-func (m *metrics) get() *State {
+type jobGroup struct {
+	repoOwner    string
+	repoName     string
+	workflowName string
+	labelSet     string
+}
+
+type jobCount struct {
+	queued     int
+	inProgress int
+}
+
+func (m *metrics) collectAggregated(ch chan<- prometheus.Metric, state *State) {
+	groups := make(map[jobGroup]*jobCount)
+	for _, run := range state.WorkflowRuns {
+		for _, job := range run.Jobs {
+			if job.Status != "queued" && job.Status != "in_progress" {
+				continue
+			}
+
+			group := jobGroup{
+				repoOwner:    job.RepoOwner,
+				repoName:     job.RepoName,
+				workflowName: run.Name,
+				labelSet:     promutil.LabelSet(job.RunnerLabels),
+			}
+			count, ok := groups[group]
+			if !ok {
+				count = &jobCount{}
+				groups[group] = count
+			}
+			if job.Status == "queued" {
+				count.queued++
+			} else {
+				count.inProgress++
+			}
+		}
+	}
+
+	for group, count := range groups {
+		labels := prometheus.Labels{
+			"repository_owner": group.repoOwner,
+			"repository_name":  group.repoName,
+			"workflow_name":    group.workflowName,
+			"label_set":        group.labelSet,
+		}
+		ch <- m.jobsQueued.Gauge(float64(count.queued), labels)
+		ch <- m.jobsInProgress.Gauge(float64(count.inProgress), labels)
+	}
+}
+
+func (m *metrics) get() (*State, int) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 
-	return m.state
+	return m.state, m.stateSizeBytes
+}
+
+func (m *metrics) getRefreshQueue() (int, time.Duration) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	return m.refreshQueueDepth, m.refreshMaxStaleness
 }
 
 func (m *metrics) update(state *State) {
@@ -100,3 +246,58 @@ func (m *metrics) update(state *State) {
 
 	m.state = state
 }
+
+func (m *metrics) observeStateSize(size int) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.stateSizeBytes = size
+}
+
+func (m *metrics) observeRefreshQueue(depth int, maxStaleness time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.refreshQueueDepth = depth
+	m.refreshMaxStaleness = maxStaleness
+}
+
+func (m *metrics) observeRefreshStaleness(staleness time.Duration) {
+	m.refreshedAfter.Observe(staleness.Seconds())
+}
+
+// observeTransition observes queue wait when job starts, and execution time
+// when job completes; prev is the last known state of job, if any.
+func (m *metrics) observeTransition(owner string, repo string, workflowName string, prev *workflowJob, job *workflowJob) {
+	// Jobs cancelled or skipped before being picked by a runner never start.
+	if job.RunnerID == nil && job.GetStatus() != "in_progress" {
+		return
+	}
+
+	labels := prometheus.Labels{
+		"repository_owner":  owner,
+		"repository_name":   repo,
+		"workflow_name":     workflowName,
+		"workflow_job_name": job.GetName(),
+		"label_set":         promutil.LabelSet(job.Labels),
+	}
+
+	prevStatus := ""
+	if prev != nil {
+		prevStatus = prev.GetStatus()
+	}
+
+	started := prevStatus == "" || prevStatus == "queued"
+	if createdAt := job.GetCreatedAt(); started && !createdAt.IsZero() && job.StartedAt != nil {
+		if wait := job.GetStartedAt().Sub(createdAt.Time); wait >= 0 {
+			m.queueWait.With(labels).Observe(wait.Seconds())
+		}
+	}
+
+	completed := job.GetStatus() == "completed" && prevStatus != "completed"
+	if completed && job.StartedAt != nil && job.CompletedAt != nil {
+		if d := job.GetCompletedAt().Sub(job.GetStartedAt().Time); d >= 0 {
+			m.execution.With(labels).Observe(d.Seconds())
+		}
+	}
+}
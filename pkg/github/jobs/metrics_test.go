@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/oursky/github-actions-manager/pkg/utils/promutil"
+
+	"github.com/prometheus/client_golang/prometheus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// gatherGauges returns values of gathered gauges by metric name, and then by
+// sorted label pairs.
+func gatherGauges(registry *prometheus.Registry) map[string]map[string]float64 {
+	families, err := registry.Gather()
+	So(err, ShouldBeNil)
+
+	result := make(map[string]map[string]float64)
+	for _, f := range families {
+		series := make(map[string]float64)
+		for _, m := range f.GetMetric() {
+			var pairs []string
+			for _, l := range m.GetLabel() {
+				pairs = append(pairs, l.GetName()+"="+l.GetValue())
+			}
+			sort.Strings(pairs)
+			series[strings.Join(pairs, ";")] = m.GetGauge().GetValue()
+		}
+		result[f.GetName()] = series
+	}
+	return result
+}
+
+func TestMetrics(t *testing.T) {
+	Convey("Given job metrics", t, func() {
+		job := func(id int64, status string, labels ...string) *WorkflowJob {
+			return &WorkflowJob{
+				Key:          Key{ID: id, RepoOwner: "acme", RepoName: "repo"},
+				Name:         "build",
+				Status:       status,
+				RunnerLabels: labels,
+			}
+		}
+		state := &State{WorkflowRuns: []*WorkflowRun{{
+			Key:  Key{ID: 1, RepoOwner: "acme", RepoName: "repo"},
+			Name: "CI",
+			Jobs: []*WorkflowJob{
+				job(1, "queued", "x64", "linux"),
+				job(2, "queued", "linux", "x64"),
+				job(3, "in_progress", "linux", "x64"),
+				job(4, "in_progress", "macos"),
+				job(5, "completed", "linux", "x64"),
+			},
+		}}}
+
+		newRegistry := func(mode promutil.MetricsMode) *prometheus.Registry {
+			registry := prometheus.NewPedanticRegistry()
+			m := newMetrics(mode, registry)
+			m.update(state)
+			return registry
+		}
+
+		Convey("Aggregated mode exports no per-job series", func() {
+			metrics := gatherGauges(newRegistry(promutil.MetricsModeAggregated))
+
+			for _, name := range []string{
+				"github_actions_job_status_queued",
+				"github_actions_job_status_in_progress",
+				"github_actions_job_status_completed",
+				"github_actions_job_start_time",
+				"github_actions_job_completion_time",
+			} {
+				So(metrics, ShouldNotContainKey, name)
+			}
+		})
+
+		Convey("Aggregated mode counts incomplete jobs by label set regardless of label order", func() {
+			metrics := gatherGauges(newRegistry(promutil.MetricsModeAggregated))
+
+			linux := "label_set=linux,x64;repository_name=repo;repository_owner=acme;workflow_name=CI"
+			macos := "label_set=macos;repository_name=repo;repository_owner=acme;workflow_name=CI"
+			So(metrics["github_actions_jobs_queued"], ShouldResemble, map[string]float64{linux: 2, macos: 0})
+			So(metrics["github_actions_jobs_in_progress"], ShouldResemble, map[string]float64{linux: 1, macos: 1})
+		})
+
+		Convey("Detailed mode exports per-job series only", func() {
+			metrics := gatherGauges(newRegistry(promutil.MetricsModeDetailed))
+
+			So(metrics["github_actions_job_status_queued"], ShouldHaveLength, 2)
+			So(metrics["github_actions_job_status_in_progress"], ShouldHaveLength, 2)
+			So(metrics["github_actions_job_status_completed"], ShouldHaveLength, 1)
+			So(metrics, ShouldNotContainKey, "github_actions_jobs_queued")
+		})
+	})
+}
@@ -0,0 +1,186 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"go.uber.org/zap"
+)
+
+type repository struct {
+	owner string
+	name  string
+}
+
+type workflowRuns struct {
+	TotalCount   int            `json:"total_count"`
+	WorkflowRuns []*workflowRun `json:"workflow_runs"`
+}
+
+// listRecentWorkflowRuns lists the first page of runs of the repository,
+// latest first. The URL is kept stable, so that unchanged runs are revalidated
+// with conditional requests by cached transport.
+func listRecentWorkflowRuns(ctx context.Context, client *github.Client, owner string, repo string, pageSize int) ([]*workflowRun, error) {
+	u := fmt.Sprintf("repos/%v/%v/actions/runs?per_page=%d", owner, repo, pageSize)
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result workflowRuns
+	if _, err := client.Do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return result.WorkflowRuns, nil
+}
+
+// AddPolledOrganization polls runs of all repositories in the organization,
+// if polling is enabled; it must be called before starting.
+func (s *Synchronizer) AddPolledOrganization(org string) {
+	s.polledOrgs = append(s.polledOrgs, org)
+}
+
+// AddPolledRepository polls runs of the repository, if polling is enabled; it
+// must be called before starting.
+func (s *Synchronizer) AddPolledRepository(owner string, name string) {
+	s.polledRepos = append(s.polledRepos, repository{owner: owner, name: name})
+}
+
+// poll discovers runs by listing recent runs of repositories periodically,
+// for repositories without webhooks. Runs are sent with their jobs when
+// changed since last poll.
+func (s *Synchronizer) poll(ctx context.Context, results chan<- reconciledRun) {
+	config := s.config.Polling
+	if config == nil {
+		return
+	}
+
+	if len(config.Repositories) == 0 && len(config.Organizations) == 0 &&
+		len(s.polledRepos) == 0 && len(s.polledOrgs) == 0 {
+		s.logger.Warn("polling is enabled without repositories to poll")
+		return
+	}
+
+	seen := make(map[RunKey]time.Time)
+	ticker := time.NewTicker(config.GetInterval())
+	defer ticker.Stop()
+
+	for {
+		s.pollRuns(ctx, seen, results)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Synchronizer) pollRuns(ctx context.Context, seen map[RunKey]time.Time, results chan<- reconciledRun) {
+	retentionLimit := time.Now().Add(-s.config.GetRetentionPeriod())
+
+	repos := s.polledRepositories(ctx)
+	if len(repos) == 0 {
+		s.logger.Warn("no repositories to poll")
+	}
+	for _, repo := range repos {
+		runs, err := listRecentWorkflowRuns(ctx, s.github, repo.owner, repo.name, s.config.GetSyncPageSize())
+		if err != nil {
+			s.logger.Warn("failed to list workflow runs",
+				zap.Error(err),
+				zap.String("owner", repo.owner),
+				zap.String("repo", repo.name),
+			)
+			continue
+		}
+
+		for _, run := range runs {
+			updatedAt := run.GetUpdatedAt().Time
+			if updatedAt.Before(retentionLimit) {
+				continue
+			}
+			key := RunKey{
+				Key:     Key{RepoOwner: repo.owner, RepoName: repo.name, ID: run.GetID()},
+				Attempt: runAttempt(run),
+			}
+			if last, ok := seen[key]; ok && !updatedAt.After(last) {
+				continue
+			}
+
+			jobs, err := listWorkflowJobs(ctx, s.github, repo.owner, repo.name, key.ID, key.Attempt)
+			if err != nil {
+				s.logger.Warn("failed to list workflow jobs",
+					zap.Error(err),
+					zap.String("owner", repo.owner),
+					zap.String("repo", repo.name),
+					zap.Int64("id", key.ID),
+					zap.Int("attempt", key.Attempt),
+				)
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case results <- reconciledRun{RunKey: key, run: run, jobs: jobs}:
+			}
+			seen[key] = updatedAt
+		}
+	}
+
+	for key, updatedAt := range seen {
+		if updatedAt.Before(retentionLimit) {
+			delete(seen, key)
+		}
+	}
+}
+
+// polledRepositories returns configured and added repositories, and
+// repositories of polled organizations.
+func (s *Synchronizer) polledRepositories(ctx context.Context) []repository {
+	config := s.config.Polling
+
+	var repos []repository
+	added := make(map[repository]bool)
+	add := func(repo repository) {
+		if !added[repo] {
+			added[repo] = true
+			repos = append(repos, repo)
+		}
+	}
+
+	for _, r := range config.Repositories {
+		owner, name, _ := strings.Cut(r, "/")
+		add(repository{owner: owner, name: name})
+	}
+	for _, repo := range s.polledRepos {
+		add(repo)
+	}
+
+	orgs := append(append([]string(nil), config.Organizations...), s.polledOrgs...)
+	for _, org := range orgs {
+		opts := &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 100}}
+		for {
+			page, resp, err := s.github.Repositories.ListByOrg(ctx, org, opts)
+			if err != nil {
+				s.logger.Warn("failed to list repositories", zap.Error(err), zap.String("org", org))
+				break
+			}
+			for _, repo := range page {
+				// Workflows of archived repositories cannot run.
+				if repo.GetArchived() {
+					continue
+				}
+				add(repository{owner: repo.GetOwner().GetLogin(), name: repo.GetName()})
+			}
+			if resp.NextPage == 0 {
+				break
+			}
+			opts.Page = resp.NextPage
+		}
+	}
+	return repos
+}
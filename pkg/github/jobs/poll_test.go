@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/github/githubtest"
+	"github.com/oursky/github-actions-manager/pkg/kv"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/prometheus/client_golang/prometheus"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestPolling(t *testing.T) {
+	Convey("Given a job synchronizer polling an organization", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := githubtest.NewServer()
+		defer server.Close()
+
+		now := time.Now()
+		run := server.SetRun("acme", "repo", &github.WorkflowRun{
+			Name:         github.String("CI"),
+			Status:       github.String("in_progress"),
+			RunStartedAt: &github.Timestamp{Time: now.Add(-time.Minute)},
+			UpdatedAt:    &github.Timestamp{Time: now},
+		})
+		job := server.SetJob("acme", "repo", &github.WorkflowJob{
+			RunID:     run.ID,
+			Name:      github.String("build"),
+			Status:    github.String("in_progress"),
+			StartedAt: &github.Timestamp{Time: now},
+		})
+
+		addr := githubtest.FreeAddr()
+		interval := 20 * time.Millisecond
+		sync, err := NewSynchronizer(
+			zap.NewNop(),
+			&Config{
+				WebhookServerAddr: &addr,
+				WebhookSecret:     "secret",
+				Polling:           &PollingConfig{Interval: &interval},
+			},
+			server.Client(),
+			kv.NewInMemoryStore(),
+			prometheus.NewPedanticRegistry(),
+		)
+		So(err, ShouldBeNil)
+		sync.AddPolledOrganization("acme")
+
+		g, ctx := errgroup.WithContext(ctx)
+		So(sync.Start(ctx, g), ShouldBeNil)
+
+		countRequests := func(path string) int {
+			count := 0
+			for _, r := range server.Requests() {
+				if r == path {
+					count++
+				}
+			}
+			return count
+		}
+		listRuns := "GET /repos/acme/repo/actions/runs"
+		listJobs := fmt.Sprintf("GET /repos/acme/repo/actions/runs/%d/attempts/1/jobs", run.GetID())
+
+		Convey("Runs of repositories are discovered without webhooks", func() {
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				state := sync.State().Value()
+				return state != nil && len(state.WorkflowRuns) == 1
+			})
+			So(ok, ShouldBeTrue)
+
+			state := sync.State().Value()
+			So(state.WorkflowRuns[0].ID, ShouldEqual, run.GetID())
+			So(state.WorkflowRuns[0].Jobs, ShouldHaveLength, 1)
+			So(state.WorkflowRuns[0].Jobs[0].ID, ShouldEqual, job.GetID())
+
+			Convey("Jobs are listed only when runs changed", func() {
+				ok := githubtest.Eventually(5*time.Second, func() bool {
+					return countRequests(listRuns) >= 3
+				})
+				So(ok, ShouldBeTrue)
+				So(countRequests(listJobs), ShouldEqual, 1)
+
+				server.SetJob("acme", "repo", &github.WorkflowJob{
+					ID:          job.ID,
+					RunID:       run.ID,
+					Name:        github.String("build"),
+					Status:      github.String("completed"),
+					Conclusion:  github.String("success"),
+					StartedAt:   &github.Timestamp{Time: now},
+					CompletedAt: &github.Timestamp{Time: now.Add(time.Minute)},
+				})
+				server.SetRun("acme", "repo", &github.WorkflowRun{
+					ID:           run.ID,
+					Name:         github.String("CI"),
+					Status:       github.String("completed"),
+					Conclusion:   github.String("success"),
+					RunStartedAt: &github.Timestamp{Time: now.Add(-time.Minute)},
+					UpdatedAt:    &github.Timestamp{Time: now.Add(time.Minute)},
+				})
+
+				ok = githubtest.Eventually(5*time.Second, func() bool {
+					state := sync.State().Value()
+					return state.WorkflowRuns[0].Status == "completed" &&
+						state.WorkflowRuns[0].Jobs[0].Status == "completed"
+				})
+				So(ok, ShouldBeTrue)
+				So(countRequests(listJobs), ShouldEqual, 2)
+			})
+		})
+	})
+
+	Convey("Added repositories are polled", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := githubtest.NewServer()
+		defer server.Close()
+
+		run := server.SetRun("acme", "repo", &github.WorkflowRun{
+			Name:      github.String("CI"),
+			Status:    github.String("in_progress"),
+			UpdatedAt: &github.Timestamp{Time: time.Now()},
+		})
+		server.SetJob("acme", "repo", &github.WorkflowJob{
+			RunID:     run.ID,
+			Name:      github.String("build"),
+			Status:    github.String("in_progress"),
+			StartedAt: &github.Timestamp{Time: time.Now()},
+		})
+
+		addr := githubtest.FreeAddr()
+		sync, err := NewSynchronizer(
+			zap.NewNop(),
+			&Config{
+				WebhookServerAddr: &addr,
+				WebhookSecret:     "secret",
+				Polling:           &PollingConfig{},
+			},
+			server.Client(),
+			kv.NewInMemoryStore(),
+			prometheus.NewPedanticRegistry(),
+		)
+		So(err, ShouldBeNil)
+		sync.AddPolledRepository("acme", "repo")
+
+		g, ctx := errgroup.WithContext(ctx)
+		So(sync.Start(ctx, g), ShouldBeNil)
+
+		ok := githubtest.Eventually(5*time.Second, func() bool {
+			state := sync.State().Value()
+			return state != nil && len(state.WorkflowRuns) == 1
+		})
+		So(ok, ShouldBeTrue)
+		So(sync.State().Value().WorkflowRuns[0].ID, ShouldEqual, run.GetID())
+	})
+}
@@ -0,0 +1,269 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"go.uber.org/zap"
+)
+
+// fullBudgetHeadroom is the quota headroom below which refresh budget is
+// scaled down, leaving quota for webhook lookups and runner synchronization.
+const fullBudgetHeadroom = 0.5
+
+const (
+	refreshRetryBackoff    = 30 * time.Second
+	refreshMaxRetryBackoff = 10 * time.Minute
+)
+
+// Quota reports remaining GitHub API quota.
+type Quota interface {
+	// Headroom returns the fraction of quota remaining.
+	Headroom() float64
+}
+
+// refreshItem is an incomplete run or job to be refreshed from GitHub API.
+type refreshItem struct {
+	runKey *RunKey
+	jobKey *Key
+
+	staleness time.Duration
+	priority  float64
+}
+
+type refreshResult struct {
+	refreshItem
+	requestedAt time.Time
+	run         *workflowRun
+	job         *workflowJob
+	err         error
+}
+
+// refreshWeight prioritizes items by their status; jobs in progress would
+// complete soon, so they are refreshed more often than queued ones.
+func refreshWeight(status string) float64 {
+	if status == "in_progress" {
+		return 2
+	}
+	return 1
+}
+
+// refreshBackoff returns delay before next refresh after consecutive
+// failures, doubling on each failure.
+func refreshBackoff(failures int) time.Duration {
+	backoff := refreshRetryBackoff
+	for i := 1; i < failures && backoff < refreshMaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > refreshMaxRetryBackoff {
+		backoff = refreshMaxRetryBackoff
+	}
+	return backoff
+}
+
+func isNotFound(err error) bool {
+	var respErr *github.ErrorResponse
+	return errors.As(err, &respErr) && respErr.Response != nil && respErr.Response.StatusCode == http.StatusNotFound
+}
+
+// refreshQueue returns incomplete runs and jobs, most stale and important
+// first. Items failed to refresh are skipped until retry.
+func (s workState) refreshQueue(now time.Time) []refreshItem {
+	var items []refreshItem
+	for k, c := range s.runs {
+		if c.Object.GetStatus() == "completed" || now.Before(c.RetryAt) {
+			continue
+		}
+		key := k
+		staleness := now.Sub(c.CheckedAt)
+		items = append(items, refreshItem{
+			runKey:    &key,
+			staleness: staleness,
+			priority:  staleness.Seconds() * refreshWeight(c.Object.GetStatus()),
+		})
+	}
+	for k, c := range s.jobs {
+		if c.Object.GetStatus() == "completed" || now.Before(c.RetryAt) {
+			continue
+		}
+		key := k
+		staleness := now.Sub(c.CheckedAt)
+		items = append(items, refreshItem{
+			jobKey:    &key,
+			staleness: staleness,
+			priority:  staleness.Seconds() * refreshWeight(c.Object.GetStatus()),
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].priority > items[j].priority
+	})
+	return items
+}
+
+// refreshBudget returns number of requests allowed in a refresh.
+func refreshBudget(budget int, headroom float64) int {
+	if headroom >= fullBudgetHeadroom {
+		return budget
+	}
+	if headroom <= 0 {
+		return 0
+	}
+	n := int(float64(budget) * headroom / fullBudgetHeadroom)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// scheduleRefresh picks items to refresh within budget, and refreshes them in
+// background; it returns false if nothing to refresh.
+func (s *Synchronizer) scheduleRefresh(ctx context.Context, st workState, results chan<- []refreshResult) bool {
+	queue := st.refreshQueue(time.Now())
+
+	var maxStaleness time.Duration
+	for _, item := range queue {
+		if item.staleness > maxStaleness {
+			maxStaleness = item.staleness
+		}
+	}
+	s.metrics.observeRefreshQueue(len(queue), maxStaleness)
+
+	headroom := 1.0
+	if s.quota != nil {
+		headroom = s.quota.Headroom()
+	}
+	budget := refreshBudget(s.config.GetRefreshBudget(), headroom)
+	if budget < len(queue) {
+		queue = queue[:budget]
+	}
+	if len(queue) == 0 {
+		return false
+	}
+
+	go func() {
+		refreshed := s.refresh(ctx, queue)
+		select {
+		case <-ctx.Done():
+		case results <- refreshed:
+		}
+	}()
+	return true
+}
+
+// refresh fetches items with bounded concurrency.
+func (s *Synchronizer) refresh(ctx context.Context, items []refreshItem) []refreshResult {
+	sem := make(chan struct{}, s.config.GetRefreshConcurrency())
+	results := make([]refreshResult, len(items))
+	wg := new(sync.WaitGroup)
+	for i, item := range items {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int, item refreshItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = s.refreshItem(ctx, item)
+		}(i, item)
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		if r.err == nil {
+			s.metrics.observeRefreshStaleness(r.staleness)
+		}
+	}
+	return results
+}
+
+func (s *Synchronizer) refreshItem(ctx context.Context, item refreshItem) refreshResult {
+	result := refreshResult{refreshItem: item, requestedAt: time.Now()}
+	if key := item.runKey; key != nil {
+		run, err := getWorkflowRun(ctx, s.github, key.RepoOwner, key.RepoName, key.ID, key.Attempt)
+		if err != nil {
+			s.logger.Warn("failed to get workflow run",
+				zap.Error(err),
+				zap.String("owner", key.RepoOwner),
+				zap.String("repo", key.RepoName),
+				zap.Int64("id", key.ID),
+				zap.Int("attempt", key.Attempt),
+			)
+			result.err = err
+			return result
+		}
+		result.run = run
+	} else if key := item.jobKey; key != nil {
+		job, err := getWorkflowJob(ctx, s.github, key.RepoOwner, key.RepoName, key.ID)
+		if err != nil {
+			s.logger.Warn("failed to get workflow job",
+				zap.Error(err),
+				zap.String("owner", key.RepoOwner),
+				zap.String("repo", key.RepoName),
+				zap.Int64("id", key.ID),
+			)
+			result.err = err
+			return result
+		}
+		result.job = job
+	}
+	return result
+}
+
+// applyRefresh updates state with refreshed items. Refreshed objects
+// override state unless updated while refreshing, e.g. by webhooks. Items not
+// found are removed, and other failed items are retried with backoff.
+func (st workState) applyRefresh(results []refreshResult) {
+	for _, r := range results {
+		if r.err != nil {
+			st.refreshFailed(r)
+			continue
+		}
+		if key := r.runKey; key != nil && r.run != nil {
+			force := !st.runs[*key].CheckedAt.After(r.requestedAt)
+			st.setRun(key.RepoOwner, key.RepoName, r.run, force)
+		}
+		if key := r.jobKey; key != nil && r.job != nil {
+			force := !st.jobs[*key].CheckedAt.After(r.requestedAt)
+			st.setJob(key.RepoOwner, key.RepoName, r.job, force)
+		}
+	}
+}
+
+func (st workState) refreshFailed(r refreshResult) {
+	if key := r.runKey; key != nil {
+		c, ok := st.runs[*key]
+		if !ok || c.CheckedAt.After(r.requestedAt) {
+			return
+		}
+		if isNotFound(r.err) {
+			delete(st.runs, *key)
+			return
+		}
+		c.RefreshFailures++
+		c.RetryAt = r.requestedAt.Add(refreshBackoff(c.RefreshFailures))
+		st.runs[*key] = c
+	}
+	if key := r.jobKey; key != nil {
+		c, ok := st.jobs[*key]
+		if !ok || c.CheckedAt.After(r.requestedAt) {
+			return
+		}
+		if isNotFound(r.err) {
+			delete(st.jobs, *key)
+			return
+		}
+		c.RefreshFailures++
+		c.RetryAt = r.requestedAt.Add(refreshBackoff(c.RefreshFailures))
+		st.jobs[*key] = c
+	}
+}
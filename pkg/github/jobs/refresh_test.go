@@ -0,0 +1,275 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/github/githubtest"
+	"github.com/oursky/github-actions-manager/pkg/kv"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/prometheus/client_golang/prometheus"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+type fakeQuota float64
+
+func (q fakeQuota) Headroom() float64 {
+	return float64(q)
+}
+
+func TestRefreshQueue(t *testing.T) {
+	Convey("Refresh queue orders items by staleness and status", t, func() {
+		now := time.Now()
+		job := func(status string, checkedAt time.Time) cell[workflowJob] {
+			return cell[workflowJob]{
+				Object:    &workflowJob{WorkflowJob: github.WorkflowJob{Status: github.String(status)}},
+				CheckedAt: checkedAt,
+			}
+		}
+		st := workState{
+			runs: map[RunKey]cell[workflowRun]{
+				{Key: Key{ID: 1}, Attempt: 1}: {
+					Object:    &workflowRun{WorkflowRun: github.WorkflowRun{Status: github.String("in_progress")}},
+					CheckedAt: now.Add(-time.Minute),
+				},
+			},
+			jobs: map[Key]cell[workflowJob]{
+				{ID: 2}: job("queued", now.Add(-3*time.Minute)),
+				{ID: 3}: job("in_progress", now.Add(-2*time.Minute)),
+				{ID: 4}: job("completed", now.Add(-time.Hour)),
+				{ID: 5}: job("queued", now.Add(-time.Second)),
+			},
+		}
+
+		var ids []int64
+		for _, item := range st.refreshQueue(now) {
+			if item.runKey != nil {
+				ids = append(ids, item.runKey.ID)
+			} else {
+				ids = append(ids, item.jobKey.ID)
+			}
+		}
+		So(ids, ShouldResemble, []int64{3, 2, 1, 5})
+	})
+
+	Convey("Failed refreshes are retried with backoff, and items not found are removed", t, func() {
+		now := time.Now()
+		failed := Key{ID: 1}
+		missing := Key{ID: 2}
+		st := workState{
+			runs: make(map[RunKey]cell[workflowRun]),
+			jobs: map[Key]cell[workflowJob]{
+				failed: {
+					Object:    &workflowJob{WorkflowJob: github.WorkflowJob{Status: github.String("queued")}},
+					CheckedAt: now.Add(-time.Hour),
+				},
+				missing: {
+					Object:    &workflowJob{WorkflowJob: github.WorkflowJob{Status: github.String("queued")}},
+					CheckedAt: now.Add(-time.Hour),
+				},
+			},
+		}
+		errStatus := func(status int) error {
+			return &github.ErrorResponse{Response: &http.Response{StatusCode: status}}
+		}
+
+		st.applyRefresh([]refreshResult{
+			{refreshItem: refreshItem{jobKey: &failed}, requestedAt: now, err: errStatus(http.StatusForbidden)},
+			{refreshItem: refreshItem{jobKey: &missing}, requestedAt: now, err: errStatus(http.StatusNotFound)},
+		})
+		So(st.jobs, ShouldNotContainKey, missing)
+		So(st.jobs[failed].RefreshFailures, ShouldEqual, 1)
+		So(st.refreshQueue(now.Add(10*time.Second)), ShouldBeEmpty)
+		So(st.refreshQueue(now.Add(time.Minute)), ShouldHaveLength, 1)
+
+		st.applyRefresh([]refreshResult{
+			{refreshItem: refreshItem{jobKey: &failed}, requestedAt: now, err: errStatus(http.StatusForbidden)},
+		})
+		So(st.refreshQueue(now.Add(50*time.Second)), ShouldBeEmpty)
+		So(st.refreshQueue(now.Add(time.Minute)), ShouldHaveLength, 1)
+
+		So(refreshBackoff(1), ShouldEqual, 30*time.Second)
+		So(refreshBackoff(3), ShouldEqual, 2*time.Minute)
+		So(refreshBackoff(10), ShouldEqual, 10*time.Minute)
+	})
+
+	Convey("Refresh budget is scaled down as quota runs low", t, func() {
+		So(refreshBudget(10, 1), ShouldEqual, 10)
+		So(refreshBudget(10, 0.5), ShouldEqual, 10)
+		So(refreshBudget(10, 0.25), ShouldEqual, 5)
+		So(refreshBudget(10, 0.01), ShouldEqual, 1)
+		So(refreshBudget(10, 0), ShouldEqual, 0)
+	})
+}
+
+func TestRefresh(t *testing.T) {
+	Convey("Given a job synchronizer refreshing frequently", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := githubtest.NewServer()
+		defer server.Close()
+
+		addr := githubtest.FreeAddr()
+		secret := "secret"
+		interval := 50 * time.Millisecond
+		budget := 2
+		registry := prometheus.NewPedanticRegistry()
+		sync, err := NewSynchronizer(
+			zap.NewNop(),
+			&Config{
+				WebhookServerAddr: &addr,
+				WebhookSecret:     secret,
+				SyncInterval:      &interval,
+				RefreshBudget:     &budget,
+			},
+			server.Client(),
+			kv.NewInMemoryStore(),
+			registry,
+		)
+		So(err, ShouldBeNil)
+		sync.SetQuota(fakeQuota(1))
+
+		g, ctx := errgroup.WithContext(ctx)
+		So(sync.Start(ctx, g), ShouldBeNil)
+
+		now := time.Now()
+		run := server.SetRun("acme", "repo", &github.WorkflowRun{
+			Name:         github.String("CI"),
+			Status:       github.String("completed"),
+			RunStartedAt: &github.Timestamp{Time: now.Add(-time.Minute)},
+			UpdatedAt:    &github.Timestamp{Time: now},
+		})
+		repo := &github.Repository{
+			Name:  github.String("repo"),
+			Owner: &github.User{Login: github.String("acme")},
+		}
+
+		Convey("All incomplete jobs are eventually refreshed", func() {
+			var jobs []*github.WorkflowJob
+			for i := 0; i < 5; i++ {
+				job := server.SetJob("acme", "repo", &github.WorkflowJob{
+					RunID:     run.ID,
+					Name:      github.String("build"),
+					Status:    github.String("in_progress"),
+					StartedAt: &github.Timestamp{Time: now},
+				})
+				err := sendWebhook(ctx, "http://"+addr, secret, "workflow_job", &github.WorkflowJobEvent{
+					Action:      github.String("in_progress"),
+					WorkflowJob: job,
+					Repo:        repo,
+				})
+				So(err, ShouldBeNil)
+				jobs = append(jobs, job)
+			}
+
+			for _, job := range jobs {
+				server.SetJob("acme", "repo", &github.WorkflowJob{
+					ID:          job.ID,
+					RunID:       run.ID,
+					Name:        github.String("build"),
+					Status:      github.String("completed"),
+					Conclusion:  github.String("success"),
+					StartedAt:   &github.Timestamp{Time: now},
+					CompletedAt: &github.Timestamp{Time: now.Add(time.Minute)},
+				})
+			}
+
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				state := sync.State().Value()
+				if state == nil || len(state.WorkflowRuns) != 1 || len(state.WorkflowRuns[0].Jobs) != len(jobs) {
+					return false
+				}
+				for _, job := range state.WorkflowRuns[0].Jobs {
+					if job.Status != "completed" {
+						return false
+					}
+				}
+				return true
+			})
+			So(ok, ShouldBeTrue)
+
+			families, err := registry.Gather()
+			So(err, ShouldBeNil)
+			var refreshed uint64
+			for _, f := range families {
+				if f.GetName() == "github_actions_jobs_refresh_staleness_seconds" {
+					refreshed = f.GetMetric()[0].GetHistogram().GetSampleCount()
+				}
+			}
+			So(refreshed, ShouldBeGreaterThanOrEqualTo, len(jobs))
+		})
+
+		Convey("Jobs failing to refresh do not starve others", func() {
+			send := func(job *github.WorkflowJob) {
+				err := sendWebhook(ctx, "http://"+addr, secret, "workflow_job", &github.WorkflowJobEvent{
+					Action:      github.String("in_progress"),
+					WorkflowJob: job,
+					Repo:        repo,
+				})
+				So(err, ShouldBeNil)
+			}
+
+			failing := server.SetJob("acme", "repo", &github.WorkflowJob{
+				RunID:     run.ID,
+				Name:      github.String("failing"),
+				Status:    github.String("in_progress"),
+				StartedAt: &github.Timestamp{Time: now},
+			})
+			server.SetFailure(fmt.Sprintf("GET /repos/acme/repo/actions/jobs/%d", failing.GetID()), http.StatusForbidden)
+			send(failing)
+			send(&github.WorkflowJob{
+				ID:        github.Int64(1),
+				RunID:     run.ID,
+				Name:      github.String("deleted"),
+				Status:    github.String("in_progress"),
+				StartedAt: &github.Timestamp{Time: now},
+			})
+
+			var jobs []*github.WorkflowJob
+			for i := 0; i < 5; i++ {
+				job := server.SetJob("acme", "repo", &github.WorkflowJob{
+					RunID:     run.ID,
+					Name:      github.String("build"),
+					Status:    github.String("in_progress"),
+					StartedAt: &github.Timestamp{Time: now},
+				})
+				send(job)
+				jobs = append(jobs, job)
+			}
+			for _, job := range jobs {
+				server.SetJob("acme", "repo", &github.WorkflowJob{
+					ID:          job.ID,
+					RunID:       run.ID,
+					Name:        github.String("build"),
+					Status:      github.String("completed"),
+					Conclusion:  github.String("success"),
+					StartedAt:   &github.Timestamp{Time: now},
+					CompletedAt: &github.Timestamp{Time: now.Add(time.Minute)},
+				})
+			}
+
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				state := sync.State().Value()
+				if state == nil || len(state.WorkflowRuns) != 1 {
+					return false
+				}
+				statuses := make(map[string][]string)
+				for _, job := range state.WorkflowRuns[0].Jobs {
+					statuses[job.Name] = append(statuses[job.Name], job.Status)
+				}
+				return len(statuses["deleted"]) == 0 &&
+					len(statuses["failing"]) == 1 &&
+					strings.Count(strings.Join(statuses["build"], ","), "completed") == len(jobs)
+			})
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
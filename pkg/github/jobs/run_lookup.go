@@ -0,0 +1,140 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/go-github/v45/github"
+	"go.uber.org/zap"
+)
+
+type lookupState int
+
+const (
+	lookupQueued lookupState = iota
+	lookupInFlight
+	// lookupAgain marks in-flight lookups requested again, which may return
+	// outdated run.
+	lookupAgain
+)
+
+type lookupResult struct {
+	RunKey
+	run *workflowRun
+}
+
+// runLookup fetches runs of jobs received from webhook with a pool of
+// workers, coalescing lookups of the same run attempt.
+type runLookup struct {
+	logger *zap.Logger
+	github *github.Client
+
+	lock    sync.Mutex
+	pending map[RunKey]lookupState
+	queue   []RunKey
+	wake    chan struct{}
+	results chan lookupResult
+}
+
+func newRunLookup(logger *zap.Logger, client *github.Client) *runLookup {
+	return &runLookup{
+		logger:  logger,
+		github:  client,
+		pending: make(map[RunKey]lookupState),
+		wake:    make(chan struct{}, 1),
+		results: make(chan lookupResult),
+	}
+}
+
+// start starts workers, which stop when ctx is done.
+func (l *runLookup) start(ctx context.Context, workers int) {
+	for i := 0; i < workers; i++ {
+		go l.work(ctx)
+	}
+}
+
+// request queues lookup of the run attempt; it never blocks. Attempt 0 looks
+// up latest attempt.
+func (l *runLookup) request(key RunKey) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	state, ok := l.pending[key]
+	switch {
+	case !ok:
+		l.pending[key] = lookupQueued
+		l.queue = append(l.queue, key)
+		l.signal()
+	case state == lookupInFlight:
+		l.pending[key] = lookupAgain
+	}
+}
+
+// signal wakes a worker; must be called with lock held.
+func (l *runLookup) signal() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (l *runLookup) next() (RunKey, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if len(l.queue) == 0 {
+		return RunKey{}, false
+	}
+	key := l.queue[0]
+	l.queue = l.queue[1:]
+	l.pending[key] = lookupInFlight
+	if len(l.queue) > 0 {
+		l.signal()
+	}
+	return key, true
+}
+
+func (l *runLookup) done(key RunKey) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.pending[key] == lookupAgain {
+		l.pending[key] = lookupQueued
+		l.queue = append(l.queue, key)
+		l.signal()
+		return
+	}
+	delete(l.pending, key)
+}
+
+func (l *runLookup) work(ctx context.Context) {
+	for {
+		key, ok := l.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-l.wake:
+			}
+			continue
+		}
+
+		run, err := getWorkflowRun(ctx, l.github, key.RepoOwner, key.RepoName, key.ID, key.Attempt)
+		if err != nil {
+			l.logger.Warn("failed to get workflow run",
+				zap.Error(err),
+				zap.String("owner", key.RepoOwner),
+				zap.String("repo", key.RepoName),
+				zap.Int64("id", key.ID),
+				zap.Int("attempt", key.Attempt),
+			)
+		} else {
+			select {
+			case <-ctx.Done():
+				return
+			case l.results <- lookupResult{RunKey: key, run: run}:
+			}
+		}
+		l.done(key)
+	}
+}
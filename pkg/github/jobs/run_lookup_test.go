@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/github/githubtest"
+
+	"github.com/google/go-github/v45/github"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func TestRunLookup(t *testing.T) {
+	Convey("Given a run lookup", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := githubtest.NewServer()
+		defer server.Close()
+
+		run := server.SetRun("acme", "repo", &github.WorkflowRun{
+			Name:   github.String("CI"),
+			Status: github.String("in_progress"),
+		})
+		lookup := newRunLookup(zap.NewNop(), server.Client())
+		key := RunKey{Key: Key{RepoOwner: "acme", RepoName: "repo", ID: run.GetID()}, Attempt: 1}
+
+		Convey("Lookups of the same run are coalesced", func() {
+			for i := 0; i < 10; i++ {
+				lookup.request(key)
+			}
+			lookup.start(ctx, 4)
+
+			select {
+			case r := <-lookup.results:
+				So(r.RunKey, ShouldResemble, key)
+				So(r.run.GetName(), ShouldEqual, "CI")
+			case <-time.After(5 * time.Second):
+				So("timeout", ShouldBeEmpty)
+			}
+
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				lookup.lock.Lock()
+				defer lookup.lock.Unlock()
+				return len(lookup.pending) == 0
+			})
+			So(ok, ShouldBeTrue)
+
+			path := fmt.Sprintf("GET /repos/acme/repo/actions/runs/%d/attempts/1", run.GetID())
+			count := 0
+			for _, r := range server.Requests() {
+				if r == path {
+					count++
+				}
+			}
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("Lookups requested while in flight are repeated once", func() {
+			lookup.request(key)
+			next, ok := lookup.next()
+			So(ok, ShouldBeTrue)
+			So(next, ShouldResemble, key)
+
+			lookup.request(key)
+			lookup.request(key)
+			_, ok = lookup.next()
+			So(ok, ShouldBeFalse)
+
+			lookup.done(key)
+			next, ok = lookup.next()
+			So(ok, ShouldBeTrue)
+			So(next, ShouldResemble, key)
+
+			lookup.done(key)
+			_, ok = lookup.next()
+			So(ok, ShouldBeFalse)
+			So(lookup.pending, ShouldBeEmpty)
+		})
+	})
+}
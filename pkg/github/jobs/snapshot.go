@@ -0,0 +1,374 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	gh "github.com/oursky/github-actions-manager/pkg/github"
+
+	"github.com/google/go-github/v45/github"
+	"go.uber.org/zap"
+)
+
+const stateSnapshotVersion = 1
+
+// maxStateSize is the size limit of state in a Kubernetes ConfigMap store,
+// leaving room for metadata of the ConfigMap.
+const maxStateSize = 1<<20 - 16<<10
+
+type snapshotCell[K any, T any] struct {
+	Key       K         `json:"key"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Object    *T        `json:"object"`
+}
+
+type stateSnapshot struct {
+	Version int                                 `json:"version"`
+	Runs    []snapshotCell[RunKey, workflowRun] `json:"runs"`
+	Jobs    []snapshotCell[Key, workflowJob]    `json:"jobs"`
+	// LastDeliveryAt is the time before which all received webhook
+	// deliveries are processed.
+	LastDeliveryAt *time.Time `json:"lastDeliveryAt,omitempty"`
+}
+
+type reconciledRun struct {
+	RunKey
+	run  *workflowRun
+	jobs []*workflowJob
+}
+
+func newStateSnapshot(st workState, lastDeliveryAt time.Time) *stateSnapshot {
+	snapshot := &stateSnapshot{Version: stateSnapshotVersion}
+	if !lastDeliveryAt.IsZero() {
+		snapshot.LastDeliveryAt = &lastDeliveryAt
+	}
+	for key, c := range st.runs {
+		// Repositories are large and mostly unused; keep only what is needed
+		// to build state.
+		run := *c.Object
+		run.Repository = nil
+		if repo := c.Object.Repository; repo != nil {
+			run.Repository = &github.Repository{HTMLURL: repo.HTMLURL}
+		}
+		run.HeadRepository = nil
+		if repo := c.Object.HeadRepository; repo != nil {
+			run.HeadRepository = &github.Repository{HTMLURL: repo.HTMLURL}
+		}
+		run.Actor = trimUser(c.Object.Actor)
+		run.TriggeringActor = trimUser(c.Object.TriggeringActor)
+		run.PullRequests = nil
+		for _, pr := range c.Object.PullRequests {
+			run.PullRequests = append(run.PullRequests, &github.PullRequest{
+				Number: pr.Number,
+				Head:   &github.PullRequestBranch{Ref: pr.GetHead().Ref},
+				Base:   &github.PullRequestBranch{Ref: pr.GetBase().Ref},
+			})
+		}
+
+		snapshot.Runs = append(snapshot.Runs, snapshotCell[RunKey, workflowRun]{
+			Key:       key,
+			UpdatedAt: c.UpdatedAt,
+			Object:    &run,
+		})
+	}
+	for key, c := range st.jobs {
+		snapshot.Jobs = append(snapshot.Jobs, snapshotCell[Key, workflowJob]{
+			Key:       key,
+			UpdatedAt: c.UpdatedAt,
+			Object:    trimJob(c.Object),
+		})
+	}
+	sort.Slice(snapshot.Runs, func(i, j int) bool {
+		a, b := snapshot.Runs[i].Key, snapshot.Runs[j].Key
+		if a.Key != b.Key {
+			return compareKey(a.Key, b.Key)
+		}
+		return a.Attempt < b.Attempt
+	})
+	sort.Slice(snapshot.Jobs, func(i, j int) bool {
+		return compareKey(snapshot.Jobs[i].Key, snapshot.Jobs[j].Key)
+	})
+	return snapshot
+}
+
+// trimJob keeps only fields of job needed to build state; steps and URLs
+// would make snapshot exceed size limit of store.
+func trimJob(j *workflowJob) *workflowJob {
+	return &workflowJob{
+		WorkflowJob: github.WorkflowJob{
+			ID:          j.ID,
+			RunID:       j.RunID,
+			Name:        j.Name,
+			HTMLURL:     j.HTMLURL,
+			Status:      j.Status,
+			Conclusion:  j.Conclusion,
+			StartedAt:   j.StartedAt,
+			CompletedAt: j.CompletedAt,
+			Labels:      j.Labels,
+			RunnerID:    j.RunnerID,
+			RunnerName:  j.RunnerName,
+		},
+		RunAttempt:   j.RunAttempt,
+		CreatedAt:    j.CreatedAt,
+		WorkflowName: j.WorkflowName,
+	}
+}
+
+func trimUser(u *github.User) *github.User {
+	if u == nil {
+		return nil
+	}
+	return &github.User{Login: u.Login}
+}
+
+func compareKey(a Key, b Key) bool {
+	if a.RepoOwner != b.RepoOwner {
+		return a.RepoOwner < b.RepoOwner
+	}
+	if a.RepoName != b.RepoName {
+		return a.RepoName < b.RepoName
+	}
+	return a.ID < b.ID
+}
+
+func (s *stateSnapshot) restore(st workState) {
+	// Restored objects were received no earlier than their last update.
+	for _, c := range s.Runs {
+		st.runs[c.Key] = cell[workflowRun]{UpdatedAt: c.UpdatedAt, Object: c.Object, CheckedAt: c.UpdatedAt}
+	}
+	for _, c := range s.Jobs {
+		st.jobs[c.Key] = cell[workflowJob]{UpdatedAt: c.UpdatedAt, Object: c.Object, CheckedAt: c.UpdatedAt}
+	}
+}
+
+// loadState restores state from saved snapshot, and returns runs that may
+// have changed while not tracked, and time of last processed delivery.
+func (s *Synchronizer) loadState(ctx context.Context, st workState) ([]RunKey, time.Time) {
+	data, err := s.kv.Get(ctx, gh.KVNamespace, KVKey)
+	if err != nil {
+		s.logger.Warn("failed to load state", zap.Error(err))
+	}
+	if len(data) == 0 {
+		return nil, time.Time{}
+	}
+
+	if !strings.HasPrefix(data, "{") {
+		s.loadLegacyState(ctx, st, s.parseLegacyState(data))
+		return nil, time.Time{}
+	}
+
+	var snapshot stateSnapshot
+	if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+		s.logger.Warn("failed to load state", zap.Error(err))
+		return nil, time.Time{}
+	}
+	if snapshot.Version != stateSnapshotVersion {
+		s.logger.Info("ignored incompatible state", zap.Int("version", snapshot.Version))
+		return nil, time.Time{}
+	}
+
+	snapshot.restore(st)
+	s.lastSaved = data
+
+	var lastDeliveryAt time.Time
+	if snapshot.LastDeliveryAt != nil {
+		lastDeliveryAt = *snapshot.LastDeliveryAt
+		s.server.deliveries.restore(lastDeliveryAt)
+	}
+
+	var keys []RunKey
+	for key, run := range st.runs {
+		if run.Object.GetStatus() != "completed" {
+			keys = append(keys, key)
+		}
+	}
+	// Runs of jobs may not be looked up before stopped.
+	missing := make(map[RunKey]bool)
+	for key, job := range st.jobs {
+		runKey := RunKey{
+			Key:     Key{RepoOwner: key.RepoOwner, RepoName: key.RepoName, ID: job.Object.GetRunID()},
+			Attempt: job.Object.GetRunAttempt(),
+		}
+		if runKey.Attempt == 0 {
+			runKey.Attempt = 1
+		}
+		if _, ok := st.runs[runKey]; !ok && !missing[runKey] {
+			missing[runKey] = true
+			keys = append(keys, runKey)
+		}
+	}
+
+	s.logger.Info("restored state",
+		zap.Int("runs", len(st.runs)),
+		zap.Int("jobs", len(st.jobs)),
+		zap.Int("incomplete", len(keys)),
+	)
+	return keys, lastDeliveryAt
+}
+
+// reconcile refreshes restored runs and their jobs one by one in background.
+func (s *Synchronizer) reconcile(ctx context.Context, keys []RunKey, results chan<- reconciledRun) {
+	for _, key := range keys {
+		run, err := getWorkflowRun(ctx, s.github, key.RepoOwner, key.RepoName, key.ID, key.Attempt)
+		if err != nil {
+			s.logger.Warn("failed to reconcile workflow run",
+				zap.Error(err),
+				zap.String("owner", key.RepoOwner),
+				zap.String("repo", key.RepoName),
+				zap.Int64("id", key.ID),
+				zap.Int("attempt", key.Attempt),
+			)
+			continue
+		}
+
+		jobs, err := listWorkflowJobs(ctx, s.github, key.RepoOwner, key.RepoName, key.ID, key.Attempt)
+		if err != nil {
+			s.logger.Warn("failed to reconcile workflow jobs",
+				zap.Error(err),
+				zap.String("owner", key.RepoOwner),
+				zap.String("repo", key.RepoName),
+				zap.Int64("id", key.ID),
+				zap.Int("attempt", key.Attempt),
+			)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case results <- reconciledRun{RunKey: key, run: run, jobs: jobs}:
+		}
+	}
+
+	if len(keys) > 0 {
+		s.logger.Info("reconciled state", zap.Int("runs", len(keys)))
+	}
+}
+
+// marshal encodes snapshot within size limit, dropping runs with all attempts
+// completed, least recently updated first. It returns number of dropped runs.
+func (s *stateSnapshot) marshal(maxSize int) ([]byte, int, error) {
+	dropped := 0
+	for {
+		data, err := json.Marshal(s)
+		if err != nil {
+			return nil, dropped, err
+		}
+		if len(data) <= maxSize {
+			return data, dropped, nil
+		}
+
+		keys := s.completedRuns()
+		if len(keys) == 0 {
+			return nil, dropped, fmt.Errorf("state exceeds size limit: %d bytes", len(data))
+		}
+		// Drop a quarter of completed runs at a time, to limit re-encoding.
+		keys = keys[:(len(keys)+3)/4]
+		s.dropRuns(keys)
+		dropped += len(keys)
+	}
+}
+
+// completedRuns returns runs with all attempts completed, least recently
+// updated first.
+func (s *stateSnapshot) completedRuns() []Key {
+	updatedAt := make(map[Key]time.Time)
+	incomplete := make(map[Key]bool)
+	for _, c := range s.Runs {
+		if c.Object.GetStatus() != "completed" {
+			incomplete[c.Key.Key] = true
+		}
+		if c.UpdatedAt.After(updatedAt[c.Key.Key]) {
+			updatedAt[c.Key.Key] = c.UpdatedAt
+		}
+	}
+
+	var keys []Key
+	for key := range updatedAt {
+		if !incomplete[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := updatedAt[keys[i]], updatedAt[keys[j]]
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return compareKey(keys[i], keys[j])
+	})
+	return keys
+}
+
+func (s *stateSnapshot) dropRuns(keys []Key) {
+	drop := make(map[Key]bool)
+	for _, key := range keys {
+		drop[key] = true
+	}
+
+	runs := s.Runs[:0]
+	for _, c := range s.Runs {
+		if !drop[c.Key.Key] {
+			runs = append(runs, c)
+		}
+	}
+	s.Runs = runs
+
+	jobs := s.Jobs[:0]
+	for _, c := range s.Jobs {
+		runKey := Key{ID: c.Object.GetRunID(), RepoOwner: c.Key.RepoOwner, RepoName: c.Key.RepoName}
+		if !drop[runKey] {
+			jobs = append(jobs, c)
+		}
+	}
+	s.Jobs = jobs
+}
+
+// saveState queues snapshot of state to be saved by saver; only latest
+// snapshot is kept if store is slower than updates.
+func (s *Synchronizer) saveState(st workState) {
+	data, dropped, err := newStateSnapshot(st, s.server.deliveries.lastProcessed()).marshal(maxStateSize)
+	if err != nil {
+		s.logger.Warn("failed to save state", zap.Error(err),
+			zap.Int("runs", len(st.runs)),
+			zap.Int("jobs", len(st.jobs)),
+		)
+		return
+	}
+	if dropped > 0 {
+		s.logger.Warn("dropped completed runs from saved state exceeding size limit of store",
+			zap.Int("dropped", dropped),
+			zap.Int("runs", len(st.runs)),
+			zap.Int("jobs", len(st.jobs)),
+		)
+	}
+	s.metrics.observeStateSize(len(data))
+
+	select {
+	case <-s.saves:
+	default:
+	}
+	s.saves <- string(data)
+}
+
+// runSaver writes queued snapshots to store, until saves is closed.
+func (s *Synchronizer) runSaver() {
+	for data := range s.saves {
+		if data == s.lastSaved {
+			continue
+		}
+
+		// Last snapshot is still written when shutting down.
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := s.kv.Set(ctx, gh.KVNamespace, KVKey, data)
+		cancel()
+		if err != nil {
+			s.logger.Warn("failed to save state", zap.Error(err), zap.Int("size", len(data)))
+			continue
+		}
+		s.lastSaved = data
+	}
+}
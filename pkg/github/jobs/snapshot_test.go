@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStateSnapshot(t *testing.T) {
+	Convey("State snapshots keep only fields needed to build state", t, func() {
+		now := time.Now()
+		key := Key{ID: 2, RepoOwner: "acme", RepoName: "repo"}
+		st := workState{
+			runs: make(map[RunKey]cell[workflowRun]),
+			jobs: map[Key]cell[workflowJob]{
+				key: {UpdatedAt: now, Object: &workflowJob{
+					WorkflowJob: github.WorkflowJob{
+						ID:          github.Int64(2),
+						RunID:       github.Int64(1),
+						Name:        github.String("build"),
+						Status:      github.String("in_progress"),
+						StartedAt:   &github.Timestamp{Time: now},
+						Labels:      []string{"linux"},
+						Steps:       []*github.TaskStep{{Name: github.String("checkout")}},
+						CheckRunURL: github.String("https://api.github.com/check-runs/2"),
+					},
+					RunAttempt: github.Int(2),
+				}},
+			},
+		}
+
+		snapshot := newStateSnapshot(st, time.Time{})
+		So(snapshot.Jobs, ShouldHaveLength, 1)
+		job := snapshot.Jobs[0].Object
+		So(job.Steps, ShouldBeNil)
+		So(job.CheckRunURL, ShouldBeNil)
+		So(job.GetName(), ShouldEqual, "build")
+		So(job.Labels, ShouldResemble, []string{"linux"})
+		So(job.GetRunAttempt(), ShouldEqual, 2)
+	})
+
+	Convey("State snapshots exceeding size limit drop least recently updated completed runs", t, func() {
+		now := time.Now()
+		st := workState{
+			runs: make(map[RunKey]cell[workflowRun]),
+			jobs: make(map[Key]cell[workflowJob]),
+		}
+		addRun := func(id int64, attempt int, status string, updatedAt time.Time) {
+			key := RunKey{Key: Key{ID: id, RepoOwner: "acme", RepoName: "repo"}, Attempt: attempt}
+			st.runs[key] = cell[workflowRun]{UpdatedAt: updatedAt, Object: &workflowRun{WorkflowRun: github.WorkflowRun{
+				ID:         github.Int64(id),
+				RunAttempt: github.Int(attempt),
+				Status:     github.String(status),
+			}}}
+			jobKey := Key{ID: id*10 + int64(attempt), RepoOwner: "acme", RepoName: "repo"}
+			st.jobs[jobKey] = cell[workflowJob]{UpdatedAt: updatedAt, Object: &workflowJob{
+				WorkflowJob: github.WorkflowJob{ID: github.Int64(jobKey.ID), RunID: github.Int64(id)},
+			}}
+		}
+		for i := int64(1); i <= 8; i++ {
+			addRun(i, 1, "completed", now.Add(time.Duration(i)*time.Minute))
+		}
+		// Runs with incomplete attempts are kept.
+		addRun(1, 2, "in_progress", now)
+
+		snapshot := newStateSnapshot(st, time.Time{})
+		full, _, err := snapshot.marshal(maxStateSize)
+		So(err, ShouldBeNil)
+
+		data, dropped, err := newStateSnapshot(st, time.Time{}).marshal(len(full) - 1)
+		So(err, ShouldBeNil)
+		So(dropped, ShouldEqual, 2)
+		So(len(data), ShouldBeLessThan, len(full))
+
+		var trimmed stateSnapshot
+		So(json.Unmarshal(data, &trimmed), ShouldBeNil)
+		var runIDs, jobIDs []int64
+		for _, c := range trimmed.Runs {
+			runIDs = append(runIDs, c.Key.ID)
+		}
+		for _, c := range trimmed.Jobs {
+			jobIDs = append(jobIDs, c.Key.ID)
+		}
+		So(runIDs, ShouldResemble, []int64{1, 1, 4, 5, 6, 7, 8})
+		So(jobIDs, ShouldResemble, []int64{11, 12, 41, 51, 61, 71, 81})
+
+		_, _, err = newStateSnapshot(st, time.Time{}).marshal(10)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -1,12 +1,12 @@
 package jobs
 
 import (
+	"fmt"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/go-github/v45/github"
 	"github.com/oursky/github-actions-manager/pkg/utils/promutil"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -21,8 +21,15 @@ type Key struct {
 	RepoName  string
 }
 
+// RunKey identifies an attempt of a workflow run.
+type RunKey struct {
+	Key
+	Attempt int
+}
+
 type WorkflowRun struct {
 	Key
+	Attempt int
 
 	Name       string
 	URL        string
@@ -33,11 +40,31 @@ type WorkflowRun struct {
 	CommitMessageTitle string
 	CommitURL          string
 
+	WorkflowID      int64
+	RunNumber       int
+	Event           string
+	HeadBranch      string
+	Actor           string
+	TriggeringActor string
+	PullRequests    []*PullRequest
+
 	Jobs []*WorkflowJob
+
+	// PreviousAttempts are earlier attempts of the run, latest first.
+	PreviousAttempts []*WorkflowRun
+}
+
+// PullRequest is a pull request the workflow run is triggered for.
+type PullRequest struct {
+	Number     int
+	URL        string
+	HeadBranch string
+	BaseBranch string
 }
 
 type WorkflowJob struct {
 	Key
+	Attempt int
 
 	Name       string
 	URL        string
@@ -73,18 +100,40 @@ func (j *WorkflowJob) labels() prometheus.Labels {
 type cell[T any] struct {
 	UpdatedAt time.Time
 	Object    *T
+	// CheckedAt is the time object is last received from GitHub.
+	CheckedAt time.Time
+	// RefreshFailures is the number of consecutive failed refreshes, which
+	// are retried no earlier than RetryAt.
+	RefreshFailures int
+	RetryAt         time.Time
 }
 
-func newState(runs map[Key]cell[github.WorkflowRun], jobs map[Key]cell[github.WorkflowJob]) *State {
-	runMap := make(map[Key]*WorkflowRun)
+func newState(runs map[RunKey]cell[workflowRun], jobs map[Key]cell[workflowJob]) *State {
+	attempts := make(map[Key][]*WorkflowRun)
 	for key, c := range runs {
 		run := c.Object
 		commitMsg := run.GetHeadCommit().GetMessage()
 		commitMsgTitle, _, _ := strings.Cut(commitMsg, "\n")
 		commitURL := run.GetHeadRepository().GetHTMLURL() + "/commit/" + run.GetHeadCommit().GetID()
 
-		runMap[key] = &WorkflowRun{
-			Key: key,
+		var pullRequests []*PullRequest
+		for _, pr := range run.PullRequests {
+			// Pull requests of runs have API URL only.
+			var url string
+			if repoURL := run.GetRepository().GetHTMLURL(); repoURL != "" {
+				url = fmt.Sprintf("%s/pull/%d", repoURL, pr.GetNumber())
+			}
+			pullRequests = append(pullRequests, &PullRequest{
+				Number:     pr.GetNumber(),
+				URL:        url,
+				HeadBranch: pr.GetHead().GetRef(),
+				BaseBranch: pr.GetBase().GetRef(),
+			})
+		}
+
+		attempts[key.Key] = append(attempts[key.Key], &WorkflowRun{
+			Key:     key.Key,
+			Attempt: key.Attempt,
 
 			Name:       run.GetName(),
 			URL:        run.GetHTMLURL(),
@@ -94,21 +143,43 @@ func newState(runs map[Key]cell[github.WorkflowRun], jobs map[Key]cell[github.Wo
 			StartedAt:          run.GetRunStartedAt().Time,
 			CommitMessageTitle: commitMsgTitle,
 			CommitURL:          commitURL,
-		}
+
+			WorkflowID:      run.GetWorkflowID(),
+			RunNumber:       run.GetRunNumber(),
+			Event:           run.GetEvent(),
+			HeadBranch:      run.GetHeadBranch(),
+			Actor:           run.GetActor().GetLogin(),
+			TriggeringActor: run.GetTriggeringActor().GetLogin(),
+			PullRequests:    pullRequests,
+		})
 	}
+	for _, runs := range attempts {
+		sort.Slice(runs, func(i, j int) bool { return runs[i].Attempt > runs[j].Attempt })
+	}
+
 	for key, c := range jobs {
 		job := c.Object
-		run, ok := runMap[Key{
+		runKey := Key{
 			ID:        job.GetRunID(),
 			RepoOwner: key.RepoOwner,
 			RepoName:  key.RepoName,
-		}]
-		if !ok {
-			continue
 		}
 
-		// FIXME: run_attempt is missing for WorkflowJob model, attempt to infer filter.
-		if job.GetStartedAt().Before(run.StartedAt) {
+		var run *WorkflowRun
+		for _, r := range attempts[runKey] {
+			if attempt := job.GetRunAttempt(); attempt != 0 {
+				if r.Attempt == attempt {
+					run = r
+					break
+				}
+			} else if !job.GetStartedAt().Before(r.StartedAt) {
+				// Run attempt is unknown; assume latest attempt started
+				// before the job.
+				run = r
+				break
+			}
+		}
+		if run == nil {
 			continue
 		}
 
@@ -123,7 +194,8 @@ func newState(runs map[Key]cell[github.WorkflowRun], jobs map[Key]cell[github.Wo
 		}
 
 		run.Jobs = append(run.Jobs, &WorkflowJob{
-			Key: key,
+			Key:     key,
+			Attempt: run.Attempt,
 
 			Name:       job.GetName(),
 			URL:        job.GetHTMLURL(),
@@ -139,11 +211,22 @@ func newState(runs map[Key]cell[github.WorkflowRun], jobs map[Key]cell[github.Wo
 	}
 
 	state := &State{}
-	for _, run := range runMap {
-		sort.Slice(run.Jobs, func(i, j int) bool {
-			return compareJob(run.Jobs[i], run.Jobs[j])
-		})
-		if len(run.Jobs) == 0 {
+	for _, runs := range attempts {
+		var run *WorkflowRun
+		for _, r := range runs {
+			if len(r.Jobs) == 0 {
+				continue
+			}
+			sort.Slice(r.Jobs, func(i, j int) bool {
+				return compareJob(r.Jobs[i], r.Jobs[j])
+			})
+			if run == nil {
+				run = r
+			} else {
+				run.PreviousAttempts = append(run.PreviousAttempts, r)
+			}
+		}
+		if run == nil {
 			continue
 		}
 		state.WorkflowRuns = append(state.WorkflowRuns, run)
@@ -3,15 +3,12 @@ package jobs
 import (
 	"context"
 	"fmt"
-	"math/rand"
-	"net/http"
-	"strconv"
-	"strings"
 	"time"
 
 	gh "github.com/oursky/github-actions-manager/pkg/github"
 	"github.com/oursky/github-actions-manager/pkg/kv"
 	"github.com/oursky/github-actions-manager/pkg/utils/channels"
+	"github.com/oursky/github-actions-manager/pkg/utils/ratelimit"
 
 	"github.com/google/go-github/v45/github"
 	"github.com/prometheus/client_golang/prometheus"
@@ -20,22 +17,33 @@ import (
 )
 
 type workState struct {
-	runs map[Key]cell[github.WorkflowRun]
-	jobs map[Key]cell[github.WorkflowJob]
+	runs map[RunKey]cell[workflowRun]
+	jobs map[Key]cell[workflowJob]
+
+	// metrics observes job transitions, if set.
+	metrics *metrics
 }
 
-func (s workState) setRun(owner string, repo string, r *github.WorkflowRun, force bool) {
-	key := Key{RepoOwner: owner, RepoName: repo, ID: r.GetID()}
+func (s workState) setRun(owner string, repo string, r *workflowRun, force bool) {
+	key := RunKey{
+		Key:     Key{RepoOwner: owner, RepoName: repo, ID: r.GetID()},
+		Attempt: runAttempt(r),
+	}
 	cell := s.runs[key]
 	updatedAt := r.GetUpdatedAt().Time
 	if updatedAt.After(cell.UpdatedAt) || force {
 		cell.Object = r
 		cell.UpdatedAt = updatedAt
+	}
+	if cell.Object != nil {
+		cell.CheckedAt = time.Now()
+		cell.RefreshFailures = 0
+		cell.RetryAt = time.Time{}
 		s.runs[key] = cell
 	}
 }
 
-func (s workState) setJob(owner string, repo string, j *github.WorkflowJob, force bool) {
+func (s workState) setJob(owner string, repo string, j *workflowJob, force bool) {
 	key := Key{RepoOwner: owner, RepoName: repo, ID: j.GetID()}
 	cell := s.jobs[key]
 	updatedAt := j.GetCompletedAt().Time
@@ -43,12 +51,44 @@ func (s workState) setJob(owner string, repo string, j *github.WorkflowJob, forc
 		updatedAt = j.GetStartedAt().Time
 	}
 	if updatedAt.After(cell.UpdatedAt) || force {
+		prev := cell.Object
 		cell.Object = j
 		cell.UpdatedAt = updatedAt
 		s.jobs[key] = cell
+
+		if s.metrics != nil {
+			s.metrics.observeTransition(owner, repo, s.workflowName(owner, repo, j), prev, j)
+		}
+	}
+	if cell.Object != nil {
+		cell.CheckedAt = time.Now()
+		cell.RefreshFailures = 0
+		cell.RetryAt = time.Time{}
+		s.jobs[key] = cell
 	}
 }
 
+// workflowName returns workflow name of the job, falling back to name of its
+// run if missing in job.
+func (s workState) workflowName(owner string, repo string, j *workflowJob) string {
+	if name := j.GetWorkflowName(); name != "" {
+		return name
+	}
+	attempt := j.GetRunAttempt()
+	if attempt == 0 {
+		attempt = 1
+	}
+	key := RunKey{
+		Key:     Key{RepoOwner: owner, RepoName: repo, ID: j.GetRunID()},
+		Attempt: attempt,
+	}
+	run := s.runs[key].Object
+	if run == nil {
+		return ""
+	}
+	return run.GetName()
+}
+
 type Synchronizer struct {
 	logger *zap.Logger
 	config *Config
@@ -58,21 +98,29 @@ type Synchronizer struct {
 
 	state   *channels.Broadcaster[*State]
 	metrics *metrics
+	quota   Quota
+
+	polledOrgs  []string
+	polledRepos []repository
+
+	saves     chan string
+	lastSaved string
 }
 
-func NewSynchronizer(logger *zap.Logger, config *Config, client *http.Client, kv kv.Store, registry *prometheus.Registry) (*Synchronizer, error) {
+func NewSynchronizer(logger *zap.Logger, config *Config, client *github.Client, kv kv.Store, registry *prometheus.Registry) (*Synchronizer, error) {
 	logger = logger.Named("jobs-sync")
 
-	server := newWebhookServer(logger, config.GetWebhookServerAddr(), config.WebhookSecret)
+	server := newWebhookServer(logger, config)
 
 	return &Synchronizer{
 		logger:  logger,
 		config:  config,
 		server:  server,
-		github:  github.NewClient(client),
+		github:  client,
 		kv:      kv,
 		state:   channels.NewBroadcaster[*State](nil),
-		metrics: newMetrics(registry),
+		metrics: newMetrics(config.MetricsMode, registry),
+		saves:   make(chan string, 1),
 	}, nil
 }
 
@@ -81,194 +129,151 @@ func (s *Synchronizer) Start(ctx context.Context, g *errgroup.Group) error {
 		return nil
 	}
 
-	runs := make(chan webhookObject[*github.WorkflowRun])
-	jobs := make(chan webhookObject[*github.WorkflowJob])
+	// Webhooks are queued, so that deliveries are acknowledged without
+	// waiting for processing.
+	runs := make(chan webhookObject[*workflowRun], s.config.GetWebhookQueueSize())
+	jobs := make(chan webhookObject[*workflowJob], s.config.GetWebhookQueueSize())
 
 	if err := s.server.Start(ctx, g, runs, jobs); err != nil {
 		return fmt.Errorf("jobs: %w", err)
 	}
 	g.Go(func() error {
-		s.run(ctx, runs, jobs)
+		s.run(gh.WithModule(ctx, "jobs-sync"), runs, jobs)
+		return nil
+	})
+	g.Go(func() error {
+		s.runSaver()
 		return nil
 	})
 	return nil
 }
 
+// AddObserver registers an observer of workflow jobs received from webhook;
+// it must be called before starting.
+func (s *Synchronizer) AddObserver(o JobObserver) {
+	s.server.observers = append(s.server.observers, o)
+}
+
+// SetQuota sets the source of API quota headroom, which refreshes adapt to;
+// it must be called before starting.
+func (s *Synchronizer) SetQuota(q Quota) {
+	s.quota = q
+}
+
 func (s *Synchronizer) State() *channels.Broadcaster[*State] {
 	return s.state
 }
 
 func (s *Synchronizer) run(
 	ctx context.Context,
-	webhookRuns <-chan webhookObject[*github.WorkflowRun],
-	webhookJobs <-chan webhookObject[*github.WorkflowJob],
+	webhookRuns <-chan webhookObject[*workflowRun],
+	webhookJobs <-chan webhookObject[*workflowJob],
 ) {
 	st := workState{
-		runs: make(map[Key]cell[github.WorkflowRun]),
-		jobs: make(map[Key]cell[github.WorkflowJob]),
+		runs:    make(map[RunKey]cell[workflowRun]),
+		jobs:    make(map[Key]cell[workflowJob]),
+		metrics: s.metrics,
 	}
 
-	s.loadState(ctx, st)
+	// Polling should yield to other requests.
+	pollCtx := ratelimit.WithPriority(ctx, ratelimit.PriorityLow)
+
+	lookup := newRunLookup(s.logger, s.github)
+	lookup.start(ctx, s.config.GetRunLookupConcurrency())
 
-	syncInterval := s.config.GetSyncInterval()
+	reconciled := make(chan reconciledRun)
+	keys, lastDeliveryAt := s.loadState(pollCtx, st)
+	go s.reconcile(pollCtx, keys, reconciled)
+	go s.recoverDeliveries(pollCtx, lastDeliveryAt)
+	go s.poll(pollCtx, reconciled)
+	s.update(st)
+
+	refreshed := make(chan []refreshResult)
+	refreshing := false
+	ticker := time.NewTicker(s.config.GetSyncInterval())
+	defer ticker.Stop()
+
+	// State is saved periodically, instead of on every update.
+	defer close(s.saves)
+	dirty := false
+	saveTicker := time.NewTicker(s.config.GetSaveInterval())
+	defer saveTicker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
+			if dirty {
+				s.saveState(st)
+			}
 			return
 
+		case <-saveTicker.C:
+			if dirty {
+				s.saveState(st)
+				dirty = false
+			}
+			continue
+
 		case o := <-webhookRuns:
 			st.setRun(o.RepoOwner, o.RepoName, o.Object, false)
+			s.server.deliveries.finish(o.ReceivedAt)
 
 		case o := <-webhookJobs:
 			st.setJob(o.RepoOwner, o.RepoName, o.Object, false)
-
-			run, _, err := s.github.Actions.GetWorkflowRunByID(ctx, o.RepoOwner, o.RepoName, o.Object.GetRunID())
-			if err != nil {
-				s.logger.Warn("failed to get workflow run",
-					zap.Error(err),
-					zap.String("owner", o.RepoOwner),
-					zap.String("repo", o.RepoName),
-					zap.Int64("id", o.Object.GetRunID()),
-				)
-				break
+			s.server.deliveries.finish(o.ReceivedAt)
+			lookup.request(RunKey{
+				Key:     Key{RepoOwner: o.RepoOwner, RepoName: o.RepoName, ID: o.Object.GetRunID()},
+				Attempt: o.Object.GetRunAttempt(),
+			})
+
+		case r := <-lookup.results:
+			st.setRun(r.RepoOwner, r.RepoName, r.run, false)
+
+		case r := <-reconciled:
+			// Webhooks received while reconciling or polling may be newer.
+			st.setRun(r.RepoOwner, r.RepoName, r.run, false)
+			for _, job := range r.jobs {
+				st.setJob(r.RepoOwner, r.RepoName, job, false)
 			}
 
-			st.setRun(o.RepoOwner, o.RepoName, run, false)
+		case results := <-refreshed:
+			refreshing = false
+			st.applyRefresh(results)
 
-		case <-time.After(syncInterval):
-			s.refreshState(ctx, st)
-		}
-
-		retentionLimit := time.Now().Add(-s.config.GetRetentionPeriod())
-
-		runRefs := make(map[Key]int)
-		for key, job := range st.jobs {
-			if job.UpdatedAt.Before(retentionLimit) {
-				delete(st.jobs, key)
-				continue
-			}
-
-			runKey := Key{ID: job.Object.GetRunID(), RepoOwner: key.RepoOwner, RepoName: key.RepoName}
-			runRefs[runKey]++
-		}
-
-		for key, run := range st.runs {
-			if run.UpdatedAt.Before(retentionLimit) {
-				delete(st.runs, key)
-				continue
+		case <-ticker.C:
+			// Refreshes taking longer than sync interval are not overlapped.
+			if !refreshing {
+				refreshing = s.scheduleRefresh(pollCtx, st, refreshed)
 			}
 		}
 
-		state := newState(st.runs, st.jobs)
-		s.state.Publish(state)
-		s.metrics.update(state)
-		s.saveState(ctx, st.runs)
+		s.update(st)
+		dirty = true
 	}
 }
 
-func (s *Synchronizer) refreshState(ctx context.Context, st workState) {
-	var updaters []func()
-
-	for k, o := range st.runs {
-		if o.Object.GetStatus() == "completed" {
-			continue
-		}
-		key := k
-		updaters = append(updaters, func() {
-			run, _, err := s.github.Actions.GetWorkflowRunByID(ctx, key.RepoOwner, key.RepoName, key.ID)
-			if err != nil {
-				s.logger.Warn("failed to get workflow run",
-					zap.Error(err),
-					zap.String("owner", key.RepoOwner),
-					zap.String("repo", key.RepoName),
-					zap.Int64("id", key.ID),
-				)
-				return
-			}
-			st.setRun(key.RepoOwner, key.RepoName, run, true)
-		})
-	}
+func (s *Synchronizer) update(st workState) {
+	retentionLimit := time.Now().Add(-s.config.GetRetentionPeriod())
 
-	for k, o := range st.jobs {
-		if o.Object.GetStatus() == "completed" {
+	runRefs := make(map[Key]int)
+	for key, job := range st.jobs {
+		if job.UpdatedAt.Before(retentionLimit) {
+			delete(st.jobs, key)
 			continue
 		}
-		key := k
-		updaters = append(updaters, func() {
-			job, _, err := s.github.Actions.GetWorkflowJobByID(ctx, key.RepoOwner, key.RepoName, key.ID)
-			if err != nil {
-				s.logger.Warn("failed to get workflow job",
-					zap.Error(err),
-					zap.String("owner", key.RepoOwner),
-					zap.String("repo", key.RepoName),
-					zap.Int64("id", key.ID),
-				)
-			}
-			st.setJob(key.RepoOwner, key.RepoName, job, true)
-		})
-	}
 
-	if len(updaters) == 0 {
-		return
+		runKey := Key{ID: job.Object.GetRunID(), RepoOwner: key.RepoOwner, RepoName: key.RepoName}
+		runRefs[runKey]++
 	}
-	updaters[rand.Intn(len(updaters))]()
-}
 
-func (s *Synchronizer) loadState(ctx context.Context, st workState) {
-	data, err := s.kv.Get(ctx, gh.KVNamespace, KVKey)
-	if err != nil {
-		s.logger.Warn("failed to load state", zap.Error(err))
-	}
-	if len(data) == 0 {
-		return
-	}
-
-	for _, k := range strings.Split(data, ";") {
-		parts := strings.Split(k, "/")
-		if len(parts) != 3 {
-			s.logger.Warn("failed to load state", zap.Error(err))
-			continue
-		}
-
-		owner := parts[0]
-		repo := parts[1]
-		id, err := strconv.ParseInt(parts[2], 10, 64)
-		if err != nil {
-			s.logger.Warn("failed to load state", zap.Error(err))
+	for key, run := range st.runs {
+		if run.UpdatedAt.Before(retentionLimit) {
+			delete(st.runs, key)
 			continue
 		}
-
-		wrun, _, err := s.github.Actions.GetWorkflowRunByID(ctx, owner, repo, id)
-		if err != nil {
-			s.logger.Warn("failed to refresh state", zap.Error(err), zap.String("key", k))
-			continue
-		}
-		st.setRun(owner, repo, wrun, true)
-
-		wjobs, _, err := s.github.Actions.ListWorkflowJobs(
-			ctx, owner, repo, id,
-			&github.ListWorkflowJobsOptions{ListOptions: github.ListOptions{PerPage: 100}},
-		)
-		if err != nil {
-			s.logger.Warn("failed to refresh state", zap.Error(err), zap.String("key", k))
-			continue
-		}
-		for _, job := range wjobs.Jobs {
-			st.setJob(owner, repo, job, true)
-		}
 	}
 
-	s.logger.Info("reloaded state", zap.Int("runs", len(st.runs)), zap.Int("jobs", len(st.jobs)))
-}
-
-func (s *Synchronizer) saveState(ctx context.Context, runs map[Key]cell[github.WorkflowRun]) {
-	var runKeys []string
-	for key := range runs {
-		runKeys = append(runKeys, fmt.Sprintf("%s/%s/%d", key.RepoOwner, key.RepoName, key.ID))
-	}
-
-	if err := s.kv.Set(ctx, gh.KVNamespace, KVKey, strings.Join(runKeys, ";")); err != nil {
-		s.logger.Warn("failed to save state", zap.Error(err))
-	}
+	state := newState(st.runs, st.jobs)
+	s.state.Publish(state)
+	s.metrics.update(state)
 }
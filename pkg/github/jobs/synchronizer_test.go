@@ -0,0 +1,427 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	gh "github.com/oursky/github-actions-manager/pkg/github"
+	"github.com/oursky/github-actions-manager/pkg/github/githubtest"
+	"github.com/oursky/github-actions-manager/pkg/kv"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/prometheus/client_golang/prometheus"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestSynchronizer(t *testing.T) {
+	Convey("Given a job synchronizer", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := githubtest.NewServer()
+		defer server.Close()
+
+		addr := githubtest.FreeAddr()
+		secret := "secret"
+		saveInterval := 10 * time.Millisecond
+		store := kv.NewInMemoryStore()
+		registry := prometheus.NewPedanticRegistry()
+		sync, err := NewSynchronizer(
+			zap.NewNop(),
+			&Config{WebhookServerAddr: &addr, WebhookSecret: secret, SaveInterval: &saveInterval},
+			server.Client(),
+			store,
+			registry,
+		)
+		So(err, ShouldBeNil)
+		observer := &jobCounter{}
+		sync.AddObserver(observer)
+
+		g, ctx := errgroup.WithContext(ctx)
+		So(sync.Start(ctx, g), ShouldBeNil)
+
+		now := time.Now()
+		run := server.SetRun("acme", "repo", &github.WorkflowRun{
+			Name:         github.String("CI"),
+			Status:       github.String("in_progress"),
+			RunStartedAt: &github.Timestamp{Time: now.Add(-time.Minute)},
+			UpdatedAt:    &github.Timestamp{Time: now},
+		})
+		job := server.SetJob("acme", "repo", &github.WorkflowJob{
+			RunID:     run.ID,
+			Name:      github.String("build"),
+			Status:    github.String("in_progress"),
+			StartedAt: &github.Timestamp{Time: now},
+		})
+		repo := &github.Repository{
+			Name:  github.String("repo"),
+			Owner: &github.User{Login: github.String("acme")},
+		}
+
+		sendJob := func(job *github.WorkflowJob) error {
+			return sendWebhook(ctx, "http://"+addr, secret, "workflow_job", &github.WorkflowJobEvent{
+				Action:      github.String(job.GetStatus()),
+				WorkflowJob: job,
+				Repo:        repo,
+			})
+		}
+
+		Convey("Jobs received from webhook are tracked with their runs", func() {
+			So(sendJob(job), ShouldBeNil)
+
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				state := sync.State().Value()
+				return state != nil && len(state.WorkflowRuns) == 1
+			})
+			So(ok, ShouldBeTrue)
+
+			state := sync.State().Value()
+			So(state.WorkflowRuns[0].ID, ShouldEqual, run.GetID())
+			So(state.WorkflowRuns[0].Name, ShouldEqual, "CI")
+			So(state.WorkflowRuns[0].Jobs, ShouldHaveLength, 1)
+			So(state.WorkflowRuns[0].Jobs[0].Name, ShouldEqual, "build")
+		})
+
+		Convey("Run details received from webhook are kept in state", func() {
+			err := sendWebhook(ctx, "http://"+addr, secret, "workflow_run", map[string]any{
+				"action": "in_progress",
+				"workflow_run": map[string]any{
+					"id":               run.GetID(),
+					"name":             "CI",
+					"status":           "in_progress",
+					"run_number":       42,
+					"run_attempt":      1,
+					"workflow_id":      7,
+					"event":            "pull_request",
+					"head_branch":      "feature",
+					"actor":            map[string]any{"login": "alice"},
+					"triggering_actor": map[string]any{"login": "bob"},
+					"pull_requests": []any{map[string]any{
+						"number": 3,
+						"head":   map[string]any{"ref": "feature"},
+						"base":   map[string]any{"ref": "main"},
+					}},
+					"repository": map[string]any{"html_url": "https://github.com/acme/repo"},
+					"updated_at": now.Add(time.Minute),
+				},
+				"repository": repo,
+			})
+			So(err, ShouldBeNil)
+			So(sendJob(job), ShouldBeNil)
+
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				state := sync.State().Value()
+				return state != nil && len(state.WorkflowRuns) == 1
+			})
+			So(ok, ShouldBeTrue)
+
+			r := sync.State().Value().WorkflowRuns[0]
+			So(r.RunNumber, ShouldEqual, 42)
+			So(r.WorkflowID, ShouldEqual, 7)
+			So(r.Event, ShouldEqual, "pull_request")
+			So(r.HeadBranch, ShouldEqual, "feature")
+			So(r.Actor, ShouldEqual, "alice")
+			So(r.TriggeringActor, ShouldEqual, "bob")
+			So(r.PullRequests, ShouldResemble, []*PullRequest{{
+				Number:     3,
+				URL:        "https://github.com/acme/repo/pull/3",
+				HeadBranch: "feature",
+				BaseBranch: "main",
+			}})
+
+			Convey("and in snapshot", func() {
+				st := workState{
+					runs: make(map[RunKey]cell[workflowRun]),
+					jobs: make(map[Key]cell[workflowJob]),
+				}
+				ok := githubtest.Eventually(5*time.Second, func() bool {
+					data, _ := store.Get(ctx, gh.KVNamespace, KVKey)
+					var snapshot stateSnapshot
+					if json.Unmarshal([]byte(data), &snapshot) != nil || len(snapshot.Jobs) == 0 {
+						return false
+					}
+					snapshot.restore(st)
+					return true
+				})
+				So(ok, ShouldBeTrue)
+				So(newState(st.runs, st.jobs).WorkflowRuns[0], ShouldResemble, r)
+			})
+		})
+
+		Convey("Jobs of run attempts are tracked separately", func() {
+			So(sendJob(job), ShouldBeNil)
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				state := sync.State().Value()
+				return state != nil && len(state.WorkflowRuns) == 1
+			})
+			So(ok, ShouldBeTrue)
+
+			server.SetRun("acme", "repo", &github.WorkflowRun{
+				ID:           run.ID,
+				RunAttempt:   github.Int(2),
+				Name:         github.String("CI"),
+				Status:       github.String("in_progress"),
+				RunStartedAt: &github.Timestamp{Time: now.Add(time.Minute)},
+				UpdatedAt:    &github.Timestamp{Time: now.Add(time.Minute)},
+			})
+			rerun := server.SetJobAttempt("acme", "repo", 2, &github.WorkflowJob{
+				RunID:     run.ID,
+				Name:      github.String("build"),
+				Status:    github.String("in_progress"),
+				StartedAt: &github.Timestamp{Time: now.Add(time.Minute)},
+			})
+			err := sendWebhook(ctx, "http://"+addr, secret, "workflow_job", map[string]any{
+				"action":       "in_progress",
+				"workflow_job": &workflowJob{WorkflowJob: *rerun, RunAttempt: github.Int(2)},
+				"repository":   repo,
+			})
+			So(err, ShouldBeNil)
+
+			ok = githubtest.Eventually(5*time.Second, func() bool {
+				state := sync.State().Value()
+				return len(state.WorkflowRuns) == 1 && state.WorkflowRuns[0].Attempt == 2
+			})
+			So(ok, ShouldBeTrue)
+
+			state := sync.State().Value()
+			So(state.WorkflowRuns[0].Jobs, ShouldHaveLength, 1)
+			So(state.WorkflowRuns[0].Jobs[0].ID, ShouldEqual, rerun.GetID())
+			So(state.WorkflowRuns[0].PreviousAttempts, ShouldHaveLength, 1)
+			So(state.WorkflowRuns[0].PreviousAttempts[0].Attempt, ShouldEqual, 1)
+			So(state.WorkflowRuns[0].PreviousAttempts[0].Jobs[0].ID, ShouldEqual, job.GetID())
+		})
+
+		Convey("Queue wait and execution time are observed on job transitions", func() {
+			histogram := func(name string) (count uint64, sum float64) {
+				families, err := registry.Gather()
+				So(err, ShouldBeNil)
+				for _, f := range families {
+					if f.GetName() != name {
+						continue
+					}
+					for _, m := range f.GetMetric() {
+						count += m.GetHistogram().GetSampleCount()
+						sum += m.GetHistogram().GetSampleSum()
+					}
+				}
+				return count, sum
+			}
+			sendJobUpdate := func(status string, update func(j *workflowJob)) {
+				j := &workflowJob{
+					WorkflowJob: github.WorkflowJob{
+						ID:        job.ID,
+						RunID:     run.ID,
+						Name:      github.String("build"),
+						Status:    github.String(status),
+						StartedAt: &github.Timestamp{Time: now.Add(-2 * time.Minute)},
+						Labels:    []string{"linux"},
+					},
+					RunAttempt:   github.Int(1),
+					CreatedAt:    &github.Timestamp{Time: now.Add(-2 * time.Minute)},
+					WorkflowName: github.String("CI"),
+				}
+				if update != nil {
+					update(j)
+				}
+				err := sendWebhook(ctx, "http://"+addr, secret, "workflow_job", map[string]any{
+					"action":       status,
+					"workflow_job": j,
+					"repository":   repo,
+				})
+				So(err, ShouldBeNil)
+			}
+
+			sendJobUpdate("queued", nil)
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				state := sync.State().Value()
+				return state != nil && len(state.WorkflowRuns) == 1
+			})
+			So(ok, ShouldBeTrue)
+			count, _ := histogram("github_actions_job_queue_wait_seconds")
+			So(count, ShouldEqual, 0)
+
+			sendJobUpdate("in_progress", func(j *workflowJob) {
+				j.StartedAt = &github.Timestamp{Time: now}
+				j.RunnerID = github.Int64(1)
+			})
+			ok = githubtest.Eventually(5*time.Second, func() bool {
+				count, _ := histogram("github_actions_job_queue_wait_seconds")
+				return count == 1
+			})
+			So(ok, ShouldBeTrue)
+			_, sum := histogram("github_actions_job_queue_wait_seconds")
+			So(sum, ShouldAlmostEqual, 120, 0.001)
+
+			sendJobUpdate("completed", func(j *workflowJob) {
+				j.StartedAt = &github.Timestamp{Time: now}
+				j.CompletedAt = &github.Timestamp{Time: now.Add(3 * time.Minute)}
+				j.RunnerID = github.Int64(1)
+			})
+			ok = githubtest.Eventually(5*time.Second, func() bool {
+				count, _ := histogram("github_actions_job_execution_seconds")
+				return count == 1
+			})
+			So(ok, ShouldBeTrue)
+			_, sum = histogram("github_actions_job_execution_seconds")
+			So(sum, ShouldAlmostEqual, 180, 0.001)
+			count, _ = histogram("github_actions_job_queue_wait_seconds")
+			So(count, ShouldEqual, 1)
+		})
+
+		Convey("State is restored from snapshot and reconciled", func() {
+			So(sendJob(job), ShouldBeNil)
+
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				// Runs of jobs are looked up asynchronously.
+				data, _ := store.Get(ctx, gh.KVNamespace, KVKey)
+				return strings.Contains(data, `"build"`) && strings.Contains(data, `"CI"`)
+			})
+			So(ok, ShouldBeTrue)
+
+			server.SetJob("acme", "repo", &github.WorkflowJob{
+				ID:          job.ID,
+				RunID:       run.ID,
+				Name:        github.String("build"),
+				Status:      github.String("completed"),
+				Conclusion:  github.String("success"),
+				StartedAt:   &github.Timestamp{Time: now},
+				CompletedAt: &github.Timestamp{Time: now.Add(time.Minute)},
+			})
+
+			restoredAddr := githubtest.FreeAddr()
+			restored, err := NewSynchronizer(
+				zap.NewNop(),
+				&Config{WebhookServerAddr: &restoredAddr, WebhookSecret: secret, SaveInterval: &saveInterval},
+				server.Client(),
+				store,
+				prometheus.NewPedanticRegistry(),
+			)
+			So(err, ShouldBeNil)
+			So(restored.Start(ctx, g), ShouldBeNil)
+
+			ok = githubtest.Eventually(5*time.Second, func() bool {
+				state := restored.State().Value()
+				return state != nil && len(state.WorkflowRuns) == 1
+			})
+			So(ok, ShouldBeTrue)
+			So(restored.State().Value().WorkflowRuns[0].Name, ShouldEqual, "CI")
+
+			ok = githubtest.Eventually(5*time.Second, func() bool {
+				state := restored.State().Value()
+				return len(state.WorkflowRuns) == 1 && state.WorkflowRuns[0].Jobs[0].Status == "completed"
+			})
+			So(ok, ShouldBeTrue)
+		})
+
+		Convey("Duplicated deliveries are processed once", func() {
+			event := &github.WorkflowJobEvent{
+				Action:      github.String("in_progress"),
+				WorkflowJob: job,
+				Repo:        repo,
+			}
+			So(sendWebhookDelivery(ctx, "http://"+addr, secret, "workflow_job", "delivery-1", event), ShouldBeNil)
+			So(sendWebhookDelivery(ctx, "http://"+addr, secret, "workflow_job", "delivery-1", event), ShouldBeNil)
+			So(observer.Count(), ShouldEqual, 1)
+
+			So(sendWebhookDelivery(ctx, "http://"+addr, secret, "workflow_job", "delivery-2", event), ShouldBeNil)
+			So(observer.Count(), ShouldEqual, 2)
+		})
+
+		Convey("Failed deliveries are redelivered on restart", func() {
+			So(sendJob(job), ShouldBeNil)
+
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				data, _ := store.Get(ctx, gh.KVNamespace, KVKey)
+				return strings.Contains(data, `"lastDeliveryAt"`)
+			})
+			So(ok, ShouldBeTrue)
+
+			hook := "repos/acme/repo/hooks/1"
+			delivery := func(guid string, event string, status int, at time.Time) *github.HookDelivery {
+				return server.AddHookDelivery(hook, &github.HookDelivery{
+					GUID:        github.String(guid),
+					Event:       github.String(event),
+					StatusCode:  github.Int(status),
+					DeliveredAt: &github.Timestamp{Time: at},
+				})
+			}
+			delivery("old", "workflow_run", 502, time.Now().Add(-time.Hour))
+			delivery("push", "push", 502, time.Now())
+			delivery("retried", "workflow_job", 502, time.Now().Add(-3*time.Minute))
+			delivery("retried", "workflow_job", 200, time.Now().Add(-3*time.Minute))
+			missed := delivery("missed", "workflow_job", 502, time.Now())
+			// Deliveries acknowledged after the last processed one may not be
+			// processed before stopping.
+			unprocessed := delivery("unprocessed", "workflow_job", 200, time.Now().Add(time.Second))
+
+			restoredAddr := githubtest.FreeAddr()
+			restored, err := NewSynchronizer(
+				zap.NewNop(),
+				&Config{
+					WebhookServerAddr: &restoredAddr,
+					WebhookSecret:     secret,
+					SaveInterval:      &saveInterval,
+					Hook:              &HookConfig{Type: HookTypeRepository, Owner: "acme", Repo: "repo", ID: 1},
+				},
+				server.Client(),
+				store,
+				prometheus.NewPedanticRegistry(),
+			)
+			So(err, ShouldBeNil)
+			So(restored.Start(ctx, g), ShouldBeNil)
+
+			// Deliveries are redelivered in order of delivery.
+			ok = githubtest.Eventually(5*time.Second, func() bool {
+				return len(server.Redeliveries(hook)) > 1
+			})
+			So(ok, ShouldBeTrue)
+			So(server.Redeliveries(hook), ShouldResemble, []int64{missed.GetID(), unprocessed.GetID()})
+		})
+
+		Convey("Invalid signatures are rejected", func() {
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				_, err := githubtest.SendWebhook(ctx, "http://"+addr, "wrong", "workflow_job", &github.WorkflowJobEvent{})
+				return err != nil && err.Error() == "unexpected status: 400"
+			})
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
+
+// sendWebhook retries until the webhook server is up.
+func sendWebhook(ctx context.Context, url string, secret string, event string, payload any) error {
+	var err error
+	githubtest.Eventually(5*time.Second, func() bool {
+		_, err = githubtest.SendWebhook(ctx, url, secret, event, payload)
+		return err == nil
+	})
+	return err
+}
+
+// sendWebhookDelivery retries until the webhook server is up.
+func sendWebhookDelivery(ctx context.Context, url string, secret string, event string, deliveryID string, payload any) error {
+	var err error
+	githubtest.Eventually(5*time.Second, func() bool {
+		err = githubtest.SendWebhookDelivery(ctx, url, secret, event, deliveryID, payload)
+		return err == nil
+	})
+	return err
+}
+
+type jobCounter struct {
+	count int32
+}
+
+func (c *jobCounter) ObserveWorkflowJob(action string, job *github.WorkflowJob) {
+	atomic.AddInt32(&c.count, 1)
+}
+
+func (c *jobCounter) Count() int {
+	return int(atomic.LoadInt32(&c.count))
+}
@@ -2,32 +2,51 @@ package jobs
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"time"
 
 	"github.com/google/go-github/v45/github"
-	"github.com/oursky/github-actions-manager/pkg/utils/channels"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 )
 
+// duplicatedDeliveryResponse is the response body of ignored duplicated
+// deliveries.
+const duplicatedDeliveryResponse = "duplicated delivery"
+
 type webhookObject[T any] struct {
 	Key
-	Object T
+	Object     T
+	ReceivedAt time.Time
+}
+
+// JobObserver is notified of workflow jobs received from webhook, before
+// they are processed by synchronizer. It must not block.
+type JobObserver interface {
+	ObserveWorkflowJob(action string, job *github.WorkflowJob)
 }
 
 type webhookServer struct {
-	logger *zap.Logger
-	addr   string
-	secret []byte
+	logger    *zap.Logger
+	addr      string
+	secret    []byte
+	observers []JobObserver
+
+	deliveries *deliveryTracker
+	journal    *journal
 }
 
-func newWebhookServer(logger *zap.Logger, addr string, secret string) *webhookServer {
+func newWebhookServer(logger *zap.Logger, config *Config) *webhookServer {
 	server := &webhookServer{
-		logger: logger.Named("webhook-server"),
-		addr:   addr,
-		secret: []byte(secret),
+		logger:     logger.Named("webhook-server"),
+		addr:       config.GetWebhookServerAddr(),
+		secret:     []byte(config.WebhookSecret),
+		deliveries: newDeliveryTracker(config.GetDeliveryDedupWindow()),
+	}
+	if config.Journal != nil {
+		server.journal = newJournal(config.Journal)
 	}
 
 	return server
@@ -36,8 +55,8 @@ func newWebhookServer(logger *zap.Logger, addr string, secret string) *webhookSe
 func (s *webhookServer) Start(
 	ctx context.Context,
 	g *errgroup.Group,
-	runs chan<- webhookObject[*github.WorkflowRun],
-	jobs chan<- webhookObject[*github.WorkflowJob],
+	runs chan<- webhookObject[*workflowRun],
+	jobs chan<- webhookObject[*workflowJob],
 ) error {
 	g.Go(func() error {
 		server := &http.Server{
@@ -46,7 +65,7 @@ func (s *webhookServer) Start(
 			WriteTimeout: 10 * time.Second,
 		}
 		server.Handler = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-			s.handle(ctx, rw, r, runs, jobs)
+			s.handle(rw, r, runs, jobs)
 		})
 
 		go func() {
@@ -56,6 +75,10 @@ func (s *webhookServer) Start(
 			server.Shutdown(ctx)
 		}()
 
+		if s.journal != nil {
+			defer s.journal.Close()
+		}
+
 		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 			return err
 		}
@@ -65,11 +88,10 @@ func (s *webhookServer) Start(
 }
 
 func (s *webhookServer) handle(
-	ctx context.Context,
 	rw http.ResponseWriter,
 	r *http.Request,
-	runs chan<- webhookObject[*github.WorkflowRun],
-	jobs chan<- webhookObject[*github.WorkflowJob],
+	runs chan<- webhookObject[*workflowRun],
+	jobs chan<- webhookObject[*workflowJob],
 ) {
 	payload, err := github.ValidatePayload(r, s.secret)
 	if err != nil {
@@ -77,38 +99,121 @@ func (s *webhookServer) handle(
 		rw.Write([]byte(err.Error()))
 		return
 	}
+	if s.journal != nil {
+		if err := s.journal.append(r, payload, time.Now()); err != nil {
+			s.logger.Warn("failed to append webhook to journal",
+				zap.Error(err),
+				zap.String("id", github.DeliveryID(r)),
+			)
+		}
+	}
+
 	event, err := github.ParseWebHook(github.WebHookType(r), payload)
 	if err != nil {
 		rw.WriteHeader(400)
 		rw.Write([]byte(err.Error()))
+		return
+	}
+
+	deliveryID := github.DeliveryID(r)
+	receivedAt := time.Now()
+	if !s.deliveries.begin(deliveryID, receivedAt) {
+		s.logger.Debug("ignored duplicated webhook",
+			zap.String("type", github.WebHookType(r)),
+			zap.String("id", deliveryID),
+		)
+		rw.Write([]byte(duplicatedDeliveryResponse))
+		return
 	}
 
 	s.logger.Info("received webhook",
 		zap.String("type", github.WebHookType(r)),
-		zap.String("id", github.DeliveryID(r)),
+		zap.String("id", deliveryID),
 	)
 
+	// Failed deliveries may be redelivered, so they should not be ignored.
+	fail := func(status int, msg string) {
+		s.deliveries.forget(deliveryID)
+		rw.WriteHeader(status)
+		rw.Write([]byte(msg))
+	}
+
 	switch event := event.(type) {
 	case *github.WorkflowRunEvent:
+		// Some run fields are not parsed by GitHub client.
+		var runEvent struct {
+			WorkflowRun *workflowRun `json:"workflow_run"`
+		}
+		if err := json.Unmarshal(payload, &runEvent); err != nil {
+			fail(400, err.Error())
+			return
+		} else if runEvent.WorkflowRun == nil {
+			fail(400, "missing workflow_run")
+			return
+		}
+
 		key := Key{
 			ID:        event.GetWorkflowRun().GetID(),
 			RepoOwner: event.GetRepo().GetOwner().GetLogin(),
 			RepoName:  event.GetRepo().GetName(),
 		}
-		channels.Send(ctx, runs, webhookObject[*github.WorkflowRun]{
-			Key:    key,
-			Object: event.GetWorkflowRun(),
+		s.deliveries.queue(receivedAt)
+		ok := enqueue(runs, webhookObject[*workflowRun]{
+			Key:        key,
+			Object:     runEvent.WorkflowRun,
+			ReceivedAt: receivedAt,
 		})
+		if !ok {
+			s.deliveries.discard(receivedAt)
+			s.logger.Warn("webhook queue is full", zap.String("id", deliveryID))
+			fail(503, "webhook queue is full")
+			return
+		}
 
 	case *github.WorkflowJobEvent:
+		// Some job fields are not parsed by GitHub client.
+		var jobEvent struct {
+			WorkflowJob *workflowJob `json:"workflow_job"`
+		}
+		if err := json.Unmarshal(payload, &jobEvent); err != nil {
+			fail(400, err.Error())
+			return
+		} else if jobEvent.WorkflowJob == nil {
+			fail(400, "missing workflow_job")
+			return
+		}
+
+		for _, o := range s.observers {
+			o.ObserveWorkflowJob(event.GetAction(), event.GetWorkflowJob())
+		}
+
 		key := Key{
 			ID:        event.GetWorkflowJob().GetID(),
 			RepoOwner: event.GetRepo().GetOwner().GetLogin(),
 			RepoName:  event.GetRepo().GetName(),
 		}
-		channels.Send(ctx, jobs, webhookObject[*github.WorkflowJob]{
-			Key:    key,
-			Object: event.GetWorkflowJob(),
+		s.deliveries.queue(receivedAt)
+		ok := enqueue(jobs, webhookObject[*workflowJob]{
+			Key:        key,
+			Object:     jobEvent.WorkflowJob,
+			ReceivedAt: receivedAt,
 		})
+		if !ok {
+			s.deliveries.discard(receivedAt)
+			s.logger.Warn("webhook queue is full", zap.String("id", deliveryID))
+			fail(503, "webhook queue is full")
+			return
+		}
+	}
+}
+
+// enqueue queues the object without blocking; it returns false if the queue
+// is full.
+func enqueue[T any](queue chan<- T, o T) bool {
+	select {
+	case queue <- o:
+		return true
+	default:
+		return false
 	}
 }
@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// workflowJob is a workflow job with fields missing in the GitHub client
+// model.
+type workflowJob struct {
+	github.WorkflowJob
+	RunAttempt   *int              `json:"run_attempt,omitempty"`
+	CreatedAt    *github.Timestamp `json:"created_at,omitempty"`
+	WorkflowName *string           `json:"workflow_name,omitempty"`
+}
+
+// GetRunAttempt returns the run attempt of the job, or 0 if unknown.
+func (j *workflowJob) GetRunAttempt() int {
+	if j == nil || j.RunAttempt == nil {
+		return 0
+	}
+	return *j.RunAttempt
+}
+
+// GetCreatedAt returns the creation time of the job, or zero if unknown.
+func (j *workflowJob) GetCreatedAt() github.Timestamp {
+	if j == nil || j.CreatedAt == nil {
+		return github.Timestamp{}
+	}
+	return *j.CreatedAt
+}
+
+// GetWorkflowName returns the workflow name of the job, or empty if unknown.
+func (j *workflowJob) GetWorkflowName() string {
+	if j == nil || j.WorkflowName == nil {
+		return ""
+	}
+	return *j.WorkflowName
+}
+
+type workflowJobs struct {
+	TotalCount int            `json:"total_count"`
+	Jobs       []*workflowJob `json:"jobs"`
+}
+
+func getWorkflowJob(ctx context.Context, client *github.Client, owner string, repo string, id int64) (*workflowJob, error) {
+	u := fmt.Sprintf("repos/%v/%v/actions/jobs/%v", owner, repo, id)
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	job := new(workflowJob)
+	if _, err := client.Do(ctx, req, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// listWorkflowJobs lists all jobs of a run attempt.
+func listWorkflowJobs(ctx context.Context, client *github.Client, owner string, repo string, runID int64, attempt int) ([]*workflowJob, error) {
+	var jobs []*workflowJob
+	page := 1
+	for page != 0 {
+		u := fmt.Sprintf("repos/%v/%v/actions/runs/%v/attempts/%v/jobs?per_page=100&page=%d", owner, repo, runID, attempt, page)
+		req, err := client.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var result workflowJobs
+		resp, err := client.Do(ctx, req, &result)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, result.Jobs...)
+		page = resp.NextPage
+	}
+	return jobs, nil
+}
@@ -0,0 +1,57 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// workflowRun is a workflow run with fields missing in the GitHub client
+// model.
+type workflowRun struct {
+	github.WorkflowRun
+	Actor           *github.User `json:"actor,omitempty"`
+	TriggeringActor *github.User `json:"triggering_actor,omitempty"`
+}
+
+// GetActor returns the user initiated the run.
+func (r *workflowRun) GetActor() *github.User {
+	if r == nil {
+		return nil
+	}
+	return r.Actor
+}
+
+// GetTriggeringActor returns the user initiated the run attempt, which
+// differs from actor for re-runs.
+func (r *workflowRun) GetTriggeringActor() *github.User {
+	if r == nil {
+		return nil
+	}
+	return r.TriggeringActor
+}
+
+func runAttempt(run *workflowRun) int {
+	if attempt := run.GetRunAttempt(); attempt > 0 {
+		return attempt
+	}
+	return 1
+}
+
+func getWorkflowRun(ctx context.Context, client *github.Client, owner string, repo string, id int64, attempt int) (*workflowRun, error) {
+	u := fmt.Sprintf("repos/%v/%v/actions/runs/%v", owner, repo, id)
+	if attempt != 0 {
+		u = fmt.Sprintf("repos/%v/%v/actions/runs/%v/attempts/%v", owner, repo, id, attempt)
+	}
+	req, err := client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	run := new(workflowRun)
+	if _, err := client.Do(ctx, req, run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
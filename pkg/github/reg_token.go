@@ -5,6 +5,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/oursky/github-actions-manager/pkg/utils/ratelimit"
+
 	"go.uber.org/zap"
 	"golang.org/x/sync/singleflight"
 )
@@ -62,7 +64,9 @@ func (s *RegistrationTokenStore) get(ctx context.Context) *regToken {
 func (s *RegistrationTokenStore) renew() (interface{}, error) {
 	s.logger.Info("fetching token")
 
-	token, err := s.target.GetRegistrationToken(context.TODO())
+	ctx := ratelimit.WithPriority(context.TODO(), ratelimit.PriorityHigh)
+	ctx = WithModule(ctx, "reg-token")
+	token, err := s.target.GetRegistrationToken(ctx)
 	if err != nil {
 		s.logger.Warn("fetch failed", zap.Error(err))
 		return nil, err
@@ -0,0 +1,37 @@
+package github
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oursky/github-actions-manager/pkg/github/githubtest"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func TestRegistrationTokenStore(t *testing.T) {
+	Convey("Given a registration token store of a repository", t, func() {
+		ctx := context.Background()
+
+		server := githubtest.NewServer()
+		defer server.Close()
+
+		target := NewTargetRepository(server.Client(), server.URL, "repo", "acme")
+		store := NewRegistrationTokenStore(zap.NewNop(), target)
+
+		Convey("Tokens are fetched once and reused until renewal", func() {
+			token1, err := store.Get(ctx)
+			So(err, ShouldBeNil)
+			token2, err := store.Get(ctx)
+			So(err, ShouldBeNil)
+
+			So(token1, ShouldNotBeEmpty)
+			So(token2, ShouldEqual, token1)
+			So(server.RegistrationTokens(), ShouldEqual, 1)
+			So(server.Requests(), ShouldResemble, []string{
+				"POST /repos/acme/repo/actions/runners/registration-token",
+			})
+		})
+	})
+}
@@ -0,0 +1,27 @@
+package github
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// addOptions appends list options to URL path for endpoints not supported by
+// the GitHub client.
+func addOptions(urlPath string, opts *github.ListOptions) (string, error) {
+	u, err := url.Parse(urlPath)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	if opts.Page != 0 {
+		q.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.PerPage != 0 {
+		q.Set("per_page", strconv.Itoa(opts.PerPage))
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
@@ -0,0 +1,61 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/google/go-github/v45/github"
+)
+
+type runnerLabelsResponse struct {
+	TotalCount int                    `json:"total_count"`
+	Labels     []*github.RunnerLabels `json:"labels"`
+}
+
+type runnerLabelsRequest struct {
+	Labels []string `json:"labels"`
+}
+
+// runnerLabelsPath returns path of labels of a runner, relative to actions
+// path of target (e.g. orgs/<org>/actions).
+func runnerLabelsPath(actionsPath string, id int64) string {
+	return fmt.Sprintf("%s/runners/%d/labels", actionsPath, id)
+}
+
+func doRunnerLabels(ctx context.Context, client *github.Client, method string, urlPath string, body interface{}) ([]*github.RunnerLabels, error) {
+	if method != "GET" {
+		ctx = WithAdminCredential(ctx)
+	}
+	r, err := client.NewRequest(method, urlPath, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(runnerLabelsResponse)
+	if _, err := client.Do(ctx, r, resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Labels, nil
+}
+
+func listRunnerLabels(ctx context.Context, client *github.Client, actionsPath string, id int64) ([]*github.RunnerLabels, error) {
+	return doRunnerLabels(ctx, client, "GET", runnerLabelsPath(actionsPath, id), nil)
+}
+
+func addRunnerLabels(ctx context.Context, client *github.Client, actionsPath string, id int64, labels []string) ([]*github.RunnerLabels, error) {
+	return doRunnerLabels(ctx, client, "POST", runnerLabelsPath(actionsPath, id), &runnerLabelsRequest{Labels: labels})
+}
+
+func setRunnerLabels(ctx context.Context, client *github.Client, actionsPath string, id int64, labels []string) ([]*github.RunnerLabels, error) {
+	if labels == nil {
+		labels = []string{}
+	}
+	return doRunnerLabels(ctx, client, "PUT", runnerLabelsPath(actionsPath, id), &runnerLabelsRequest{Labels: labels})
+}
+
+func removeRunnerLabel(ctx context.Context, client *github.Client, actionsPath string, id int64, label string) ([]*github.RunnerLabels, error) {
+	urlPath := runnerLabelsPath(actionsPath, id) + "/" + url.PathEscape(label)
+	return doRunnerLabels(ctx, client, "DELETE", urlPath, nil)
+}
@@ -0,0 +1,94 @@
+package runners
+
+import (
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"go.uber.org/zap"
+)
+
+const activityQueueSize = 256
+
+// activity is busy state of a runner reported by workflow_job webhook.
+type activity struct {
+	runnerID   int64
+	runnerName string
+	busy       bool
+	observedAt time.Time
+}
+
+// ObserveWorkflowJob updates busy state of runners from workflow_job
+// webhook, between synchronization epochs.
+func (s *Synchronizer) ObserveWorkflowJob(action string, job *github.WorkflowJob) {
+	if job.GetRunnerName() == "" {
+		return
+	}
+
+	var a activity
+	switch action {
+	case "in_progress":
+		a = activity{busy: true, observedAt: job.GetStartedAt().Time}
+	case "completed":
+		a = activity{busy: false, observedAt: job.GetCompletedAt().Time}
+	default:
+		return
+	}
+	a.runnerID = job.GetRunnerID()
+	a.runnerName = job.GetRunnerName()
+	if a.observedAt.IsZero() {
+		a.observedAt = time.Now()
+	}
+
+	select {
+	case s.activities <- a:
+	default:
+		s.logger.Warn("activity queue is full, dropping", zap.String("runnerName", a.runnerName))
+	}
+}
+
+// applyActivity records the activity, and returns the state with it applied
+// if busy state of runner is changed.
+func (s *Synchronizer) applyActivity(state *State, a activity) *State {
+	if last, ok := s.overlay[a.runnerName]; ok && last.observedAt.After(a.observedAt) {
+		return nil
+	}
+
+	inst, ok := state.Lookup(a.runnerName, a.runnerID)
+	if !ok {
+		// The runner may belong to other targets, or not synchronized yet.
+		return nil
+	}
+	s.overlay[a.runnerName] = a
+
+	if inst.IsBusy == a.busy {
+		return nil
+	}
+
+	instances := make(map[string]Instance, len(state.Instances))
+	for name, i := range state.Instances {
+		instances[name] = i
+	}
+	inst.IsBusy = a.busy
+	instances[a.runnerName] = *inst
+
+	return &State{Epoch: state.Epoch, Instances: instances}
+}
+
+// applyOverlay applies activities observed after the polling began to the
+// polled state; older activities are superseded by polled state.
+func (s *Synchronizer) applyOverlay(state *State, pollBeginTime time.Time) {
+	for name, a := range s.overlay {
+		if !a.observedAt.After(pollBeginTime) {
+			delete(s.overlay, name)
+			continue
+		}
+
+		inst, ok := state.Lookup(a.runnerName, a.runnerID)
+		if !ok {
+			delete(s.overlay, name)
+			continue
+		}
+		inst.IsBusy = a.busy
+		state.Instances[name] = *inst
+	}
+}
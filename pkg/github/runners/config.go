@@ -4,11 +4,16 @@ import (
 	"time"
 
 	"github.com/oursky/github-actions-manager/pkg/utils/defaults"
+	"github.com/oursky/github-actions-manager/pkg/utils/promutil"
 )
 
 type Config struct {
 	SyncInterval *time.Duration
 	SyncPageSize *int `validate:"omitempty,min=1,max=100"`
+	// UtilizationRetention is the period of runner utilization history kept.
+	UtilizationRetention *time.Duration
+	MetricsMode          promutil.MetricsMode `validate:"omitempty,oneof=Detailed Aggregated All"`
+	Reaper               ReaperConfig
 }
 
 func (c *Config) GetSyncInterval() time.Duration {
@@ -18,3 +23,26 @@ func (c *Config) GetSyncInterval() time.Duration {
 func (c *Config) GetSyncPageSize() int {
 	return defaults.Value(c.SyncPageSize, 100)
 }
+
+func (c *Config) GetUtilizationRetention() time.Duration {
+	return defaults.Value(c.UtilizationRetention, 7*24*time.Hour)
+}
+
+type ReaperConfig struct {
+	Enabled bool
+	// DryRun only logs runners that would be deleted.
+	DryRun           bool
+	OfflineThreshold *time.Duration
+	// IncludeNames and IncludeLabels limit reaped runners to those matching
+	// any name pattern or label, if configured.
+	IncludeNames  []string
+	IncludeLabels []string
+	// ExcludeNames and ExcludeLabels prevent matching runners from being
+	// reaped.
+	ExcludeNames  []string
+	ExcludeLabels []string
+}
+
+func (c *ReaperConfig) GetOfflineThreshold() time.Duration {
+	return defaults.Value(c.OfflineThreshold, 24*time.Hour)
+}
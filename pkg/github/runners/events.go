@@ -0,0 +1,134 @@
+package runners
+
+import (
+	"sort"
+	"time"
+
+	"k8s.io/utils/strings/slices"
+)
+
+// maxEventHistory is the number of recent events kept for subscribers.
+const maxEventHistory = 1024
+
+type EventType string
+
+const (
+	EventAdded         EventType = "added"
+	EventRemoved       EventType = "removed"
+	EventOnline        EventType = "online"
+	EventOffline       EventType = "offline"
+	EventBusy          EventType = "busy"
+	EventIdle          EventType = "idle"
+	EventLabelsChanged EventType = "labelsChanged"
+)
+
+// Event is a change of a runner observed between synchronization epochs.
+type Event struct {
+	Seq        uint64    `json:"seq"`
+	Type       EventType `json:"type"`
+	Epoch      int64     `json:"epoch"`
+	ObservedAt time.Time `json:"observedAt"`
+	Runner     Instance  `json:"runner"`
+}
+
+// Events contains recent events ordered by sequence number. Published values
+// may be coalesced, so subscribers should track the last processed sequence
+// number.
+type Events struct {
+	Events []Event
+}
+
+// Since returns events after the sequence number.
+func (e *Events) Since(seq uint64) []Event {
+	if e == nil {
+		return nil
+	}
+	i := sort.Search(len(e.Events), func(i int) bool { return e.Events[i].Seq > seq })
+	return e.Events[i:]
+}
+
+// append returns new events with changes appended, assigning sequence numbers.
+func (e *Events) append(changes []Event) *Events {
+	var seq uint64
+	var events []Event
+	if e != nil {
+		events = e.Events
+		if len(events) > 0 {
+			seq = events[len(events)-1].Seq
+		}
+	}
+
+	next := make([]Event, 0, len(events)+len(changes))
+	next = append(next, events...)
+	for _, c := range changes {
+		seq++
+		c.Seq = seq
+		next = append(next, c)
+	}
+	if len(next) > maxEventHistory {
+		next = next[len(next)-maxEventHistory:]
+	}
+	return &Events{Events: next}
+}
+
+func diffStates(prev *State, next *State, observedAt time.Time) []Event {
+	var events []Event
+	add := func(t EventType, i Instance) {
+		events = append(events, Event{
+			Type:       t,
+			Epoch:      next.Epoch,
+			ObservedAt: observedAt,
+			Runner:     i,
+		})
+	}
+
+	var prevInstances map[string]Instance
+	if prev != nil {
+		prevInstances = prev.Instances
+	}
+
+	for _, name := range sortedNames(prevInstances) {
+		p := prevInstances[name]
+		if n, ok := next.Instances[name]; !ok || n.ID != p.ID {
+			add(EventRemoved, p)
+		}
+	}
+
+	for _, name := range sortedNames(next.Instances) {
+		n := next.Instances[name]
+		p, ok := prevInstances[name]
+		if !ok || p.ID != n.ID {
+			add(EventAdded, n)
+			continue
+		}
+
+		if p.IsOnline != n.IsOnline {
+			if n.IsOnline {
+				add(EventOnline, n)
+			} else {
+				add(EventOffline, n)
+			}
+		}
+		if p.IsBusy != n.IsBusy {
+			if n.IsBusy {
+				add(EventBusy, n)
+			} else {
+				add(EventIdle, n)
+			}
+		}
+		if !slices.Equal(p.Labels, n.Labels) {
+			add(EventLabelsChanged, n)
+		}
+	}
+
+	return events
+}
+
+func sortedNames(instances map[string]Instance) []string {
+	names := make([]string, 0, len(instances))
+	for name := range instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -8,18 +8,39 @@ import (
 )
 
 type metrics struct {
-	state *State
-	lock  *sync.RWMutex
+	mode        promutil.MetricsMode
+	target      string
+	state       *State
+	utilization *utilization
+	lock        *sync.RWMutex
 
 	epoch  *promutil.MetricDesc
 	busy   *promutil.MetricDesc
 	online *promutil.MetricDesc
+	events *promutil.MetricDesc
+
+	busySeconds        *promutil.MetricDesc
+	idleSeconds        *promutil.MetricDesc
+	offlineSeconds     *promutil.MetricDesc
+	poolBusySeconds    *promutil.MetricDesc
+	poolIdleSeconds    *promutil.MetricDesc
+	poolOfflineSeconds *promutil.MetricDesc
+	poolRunners        *promutil.MetricDesc
+	poolOnline         *promutil.MetricDesc
+	poolBusy           *promutil.MetricDesc
+
+	eventCounts map[EventType]int
 }
 
-func newMetrics(state *State, r *prometheus.Registry) *metrics {
+func newMetrics(mode promutil.MetricsMode, target string, state *State, utilization *utilization, r *prometheus.Registry) *metrics {
 	m := &metrics{
-		state: state,
-		lock:  new(sync.RWMutex),
+		mode:        mode,
+		target:      target,
+		state:       state,
+		utilization: utilization,
+		lock:        new(sync.RWMutex),
+
+		eventCounts: make(map[EventType]int),
 
 		epoch: promutil.NewMetricDesc(prometheus.Opts{
 			Namespace: "github_actions",
@@ -39,6 +60,66 @@ func newMetrics(state *State, r *prometheus.Registry) *metrics {
 			Name:      "online",
 			Help:      "Describes whether the runner is online.",
 		}),
+		events: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "runner",
+			Name:      "events_total",
+			Help:      "Number of runner change events.",
+		}),
+		busySeconds: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "runner",
+			Name:      "busy_seconds_total",
+			Help:      "Time the runner is busy.",
+		}),
+		idleSeconds: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "runner",
+			Name:      "idle_seconds_total",
+			Help:      "Time the runner is online and idle.",
+		}),
+		offlineSeconds: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "runner",
+			Name:      "offline_seconds_total",
+			Help:      "Time the runner is offline.",
+		}),
+		poolBusySeconds: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "runner_pool",
+			Name:      "busy_seconds_total",
+			Help:      "Total time runners with the label set are busy.",
+		}),
+		poolIdleSeconds: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "runner_pool",
+			Name:      "idle_seconds_total",
+			Help:      "Total time runners with the label set are online and idle.",
+		}),
+		poolOfflineSeconds: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "runner_pool",
+			Name:      "offline_seconds_total",
+			Help:      "Total time runners with the label set are offline.",
+		}),
+		poolRunners: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "runner_pool",
+			Name:      "runners",
+			Help:      "Number of runners with the label set.",
+		}),
+		poolOnline: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "runner_pool",
+			Name:      "online",
+			Help:      "Number of online runners with the label set.",
+		}),
+		poolBusy: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "runner_pool",
+			Name:      "busy",
+			Help:      "Number of busy runners with the label set.",
+		}),
 	}
 	r.MustRegister(m)
 	return m
@@ -47,11 +128,39 @@ func newMetrics(state *State, r *prometheus.Registry) *metrics {
 func (m *metrics) Describe(ch chan<- *prometheus.Desc) {}
 
 func (m *metrics) Collect(ch chan<- prometheus.Metric) {
-	state := m.get()
+	state, eventCounts := m.get()
+
+	ch <- m.epoch.Counter(float64(state.Epoch), prometheus.Labels{"target": m.target})
+	for t, count := range eventCounts {
+		ch <- m.events.Counter(float64(count), prometheus.Labels{"target": m.target, "type": string(t)})
+	}
+	runnerTotals, labelSetTotals := m.utilization.totals()
+	for _, t := range labelSetTotals {
+		labels := prometheus.Labels{"target": m.target, "label_set": promutil.LabelSet(t.labels)}
+		ch <- m.poolBusySeconds.Counter(t.Busy.Seconds(), labels)
+		ch <- m.poolIdleSeconds.Counter(t.Idle.Seconds(), labels)
+		ch <- m.poolOfflineSeconds.Counter(t.Offline.Seconds(), labels)
+	}
+
+	if m.mode.Aggregated() {
+		m.collectAggregated(ch, state)
+	}
+	if !m.mode.Detailed() {
+		return
+	}
+
+	for name, t := range runnerTotals {
+		i := Instance{Name: name, Labels: t.labels}
+		labels := i.utilizationLabels()
+		labels["target"] = m.target
+		ch <- m.busySeconds.Counter(t.Busy.Seconds(), labels)
+		ch <- m.idleSeconds.Counter(t.Idle.Seconds(), labels)
+		ch <- m.offlineSeconds.Counter(t.Offline.Seconds(), labels)
+	}
 
-	ch <- m.epoch.Counter(float64(state.Epoch), nil)
 	for _, i := range state.Instances {
 		labels := i.labels()
+		labels["target"] = m.target
 		if i.IsBusy {
 			ch <- m.busy.Gauge(1, labels)
 		}
@@ -61,11 +170,47 @@ func (m *metrics) Collect(ch chan<- prometheus.Metric) {
 	}
 }
 
-func (m *metrics) get() *State {
+type poolCount struct {
+	runners int
+	online  int
+	busy    int
+}
+
+func (m *metrics) collectAggregated(ch chan<- prometheus.Metric, state *State) {
+	pools := make(map[string]*poolCount)
+	for _, i := range state.Instances {
+		labelSet := promutil.LabelSet(i.Labels)
+		pool, ok := pools[labelSet]
+		if !ok {
+			pool = &poolCount{}
+			pools[labelSet] = pool
+		}
+		pool.runners++
+		if i.IsOnline {
+			pool.online++
+		}
+		if i.IsBusy {
+			pool.busy++
+		}
+	}
+
+	for labelSet, pool := range pools {
+		labels := prometheus.Labels{"target": m.target, "label_set": labelSet}
+		ch <- m.poolRunners.Gauge(float64(pool.runners), labels)
+		ch <- m.poolOnline.Gauge(float64(pool.online), labels)
+		ch <- m.poolBusy.Gauge(float64(pool.busy), labels)
+	}
+}
+
+func (m *metrics) get() (*State, map[EventType]int) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 
-	return m.state
+	eventCounts := make(map[EventType]int, len(m.eventCounts))
+	for t, count := range m.eventCounts {
+		eventCounts[t] = count
+	}
+	return m.state, eventCounts
 }
 
 func (m *metrics) update(state *State) {
@@ -74,3 +219,12 @@ func (m *metrics) update(state *State) {
 
 	m.state = state
 }
+
+func (m *metrics) observeEvents(events []Event) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for _, e := range events {
+		m.eventCounts[e.Type]++
+	}
+}
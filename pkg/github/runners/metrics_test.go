@@ -0,0 +1,95 @@
+package runners
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/utils/promutil"
+
+	"github.com/prometheus/client_golang/prometheus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// gatherMetrics returns values of gathered series by metric name, and then by
+// sorted label pairs.
+func gatherMetrics(registry *prometheus.Registry) map[string]map[string]float64 {
+	families, err := registry.Gather()
+	So(err, ShouldBeNil)
+
+	result := make(map[string]map[string]float64)
+	for _, f := range families {
+		series := make(map[string]float64)
+		for _, m := range f.GetMetric() {
+			var pairs []string
+			for _, l := range m.GetLabel() {
+				pairs = append(pairs, l.GetName()+"="+l.GetValue())
+			}
+			sort.Strings(pairs)
+
+			value := m.GetGauge().GetValue()
+			if m.Counter != nil {
+				value = m.GetCounter().GetValue()
+			}
+			series[strings.Join(pairs, ";")] = value
+		}
+		result[f.GetName()] = series
+	}
+	return result
+}
+
+func TestMetrics(t *testing.T) {
+	Convey("Given runner metrics", t, func() {
+		state := &State{Epoch: 1, Instances: map[string]Instance{
+			"a": {ID: 1, Name: "a", IsOnline: true, IsBusy: true, Labels: []string{"x64", "linux"}},
+			"b": {ID: 2, Name: "b", IsOnline: true, Labels: []string{"linux", "x64"}},
+			"c": {ID: 3, Name: "c", Labels: []string{"macos"}},
+		}}
+		u := newUtilization(24 * time.Hour)
+		now := time.Now()
+		u.observe(state, now)
+		u.observe(state, now.Add(time.Minute))
+
+		newRegistry := func(mode promutil.MetricsMode) *prometheus.Registry {
+			registry := prometheus.NewPedanticRegistry()
+			newMetrics(mode, "acme", state, u, registry)
+			return registry
+		}
+
+		Convey("Aggregated mode exports no per-runner series", func() {
+			metrics := gatherMetrics(newRegistry(promutil.MetricsModeAggregated))
+
+			for _, name := range []string{
+				"github_actions_runner_busy",
+				"github_actions_runner_online",
+				"github_actions_runner_busy_seconds_total",
+				"github_actions_runner_idle_seconds_total",
+				"github_actions_runner_offline_seconds_total",
+			} {
+				So(metrics, ShouldNotContainKey, name)
+			}
+		})
+
+		Convey("Aggregated mode counts runners by label set regardless of label order", func() {
+			metrics := gatherMetrics(newRegistry(promutil.MetricsModeAggregated))
+
+			linux := "label_set=linux,x64;target=acme"
+			macos := "label_set=macos;target=acme"
+			So(metrics["github_actions_runner_pool_runners"], ShouldResemble, map[string]float64{linux: 2, macos: 1})
+			So(metrics["github_actions_runner_pool_online"], ShouldResemble, map[string]float64{linux: 2, macos: 0})
+			So(metrics["github_actions_runner_pool_busy"], ShouldResemble, map[string]float64{linux: 1, macos: 0})
+			So(metrics["github_actions_runner_pool_busy_seconds_total"], ShouldResemble, map[string]float64{linux: 60, macos: 0})
+			So(metrics["github_actions_runner_pool_idle_seconds_total"], ShouldResemble, map[string]float64{linux: 60, macos: 0})
+			So(metrics["github_actions_runner_pool_offline_seconds_total"], ShouldResemble, map[string]float64{linux: 0, macos: 60})
+		})
+
+		Convey("Detailed mode exports per-runner series only", func() {
+			metrics := gatherMetrics(newRegistry(promutil.MetricsModeDetailed))
+
+			So(metrics["github_actions_runner_online"], ShouldHaveLength, 2)
+			So(metrics["github_actions_runner_busy"], ShouldHaveLength, 1)
+			So(metrics, ShouldNotContainKey, "github_actions_runner_pool_runners")
+		})
+	})
+}
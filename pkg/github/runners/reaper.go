@@ -0,0 +1,315 @@
+package runners
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/github"
+	"github.com/oursky/github-actions-manager/pkg/kv"
+	"github.com/oursky/github-actions-manager/pkg/utils/channels"
+	"github.com/oursky/github-actions-manager/pkg/utils/promutil"
+	"github.com/oursky/github-actions-manager/pkg/utils/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+var kvNamespace = kv.RegisterNamespace("github-runner-reaper")
+
+type reaperResult string
+
+const (
+	reaperResultDeleted reaperResult = "deleted"
+	reaperResultFailed  reaperResult = "failed"
+	reaperResultDryRun  reaperResult = "dry_run"
+)
+
+const (
+	reaperRetryBackoff    = time.Minute
+	reaperMaxRetryBackoff = time.Hour
+)
+
+type reaperRunner struct {
+	id           int64
+	offlineSince time.Time
+	reported     bool
+
+	failures  int
+	nextRetry time.Time
+}
+
+// offlineRunner is a runner observed offline, persisted so that offline time
+// is kept across restarts.
+type offlineRunner struct {
+	ID           int64     `json:"id"`
+	OfflineSince time.Time `json:"offlineSince"`
+}
+
+// retryBackoff returns delay before next deletion attempt, doubling on each
+// failed attempt.
+func (r *reaperRunner) retryBackoff() time.Duration {
+	backoff := reaperRetryBackoff
+	for i := 1; i < r.failures && backoff < reaperMaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > reaperMaxRetryBackoff {
+		backoff = reaperMaxRetryBackoff
+	}
+	return backoff
+}
+
+// Reaper deletes runners that stay offline longer than configured threshold.
+type Reaper struct {
+	logger *zap.Logger
+	config *ReaperConfig
+	id     string
+	target github.Target
+	sync   *Synchronizer
+	kv     kv.Store
+
+	lock       *sync.Mutex
+	candidates int
+	results    map[reaperResult]int
+
+	candidatesDesc *promutil.MetricDesc
+	resultsDesc    *promutil.MetricDesc
+}
+
+func NewReaper(logger *zap.Logger, config *ReaperConfig, synchronizer *Synchronizer, kv kv.Store, registry *prometheus.Registry) *Reaper {
+	r := &Reaper{
+		logger: logger.Named("runner-reaper").With(zap.String("target", synchronizer.ID())),
+		config: config,
+		id:     synchronizer.ID(),
+		target: synchronizer.target,
+		sync:   synchronizer,
+		kv:     kv,
+
+		lock:    new(sync.Mutex),
+		results: make(map[reaperResult]int),
+
+		candidatesDesc: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "runner_reaper",
+			Name:      "candidates",
+			Help:      "Number of offline runners exceeding threshold.",
+		}),
+		resultsDesc: promutil.NewMetricDesc(prometheus.Opts{
+			Namespace: "github_actions",
+			Subsystem: "runner_reaper",
+			Name:      "runners_total",
+			Help:      "Number of runners reaped by result.",
+		}),
+	}
+	if config.Enabled {
+		registry.MustRegister(r)
+	}
+	return r
+}
+
+func (r *Reaper) Start(ctx context.Context, g *errgroup.Group) error {
+	if !r.config.Enabled {
+		return nil
+	}
+
+	r.logger.Info("starting reaper",
+		zap.Duration("threshold", r.config.GetOfflineThreshold()),
+		zap.Bool("dryRun", r.config.DryRun),
+	)
+	ctx = github.WithModule(ctx, "runner-reaper")
+	ctx = ratelimit.WithPriority(ctx, ratelimit.PriorityLow)
+	g.Go(func() error {
+		r.run(ctx)
+		return nil
+	})
+	return nil
+}
+
+func (r *Reaper) Describe(ch chan<- *prometheus.Desc) {}
+
+func (r *Reaper) Collect(ch chan<- prometheus.Metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	ch <- r.candidatesDesc.Gauge(float64(r.candidates), prometheus.Labels{"target": r.id})
+	for result, count := range r.results {
+		ch <- r.resultsDesc.Counter(float64(count), prometheus.Labels{
+			"target": r.id,
+			"result": string(result),
+		})
+	}
+}
+
+func (r *Reaper) run(ctx context.Context) {
+	runners := r.load(ctx)
+	epoch := int64(0)
+	sub := channels.NewSubscriber(ctx, r.sync.State())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case state := <-sub.Wait():
+			if state == nil || state.Epoch == epoch {
+				continue
+			}
+			epoch = state.Epoch
+			if r.reap(ctx, runners, state, time.Now()) {
+				r.save(ctx, runners)
+			}
+		}
+	}
+}
+
+// load loads offline runners observed before restart.
+func (r *Reaper) load(ctx context.Context) map[string]*reaperRunner {
+	runners := make(map[string]*reaperRunner)
+
+	data, err := r.kv.Get(ctx, kvNamespace, r.id)
+	if err != nil {
+		r.logger.Warn("failed to load offline runners", zap.Error(err))
+		return runners
+	} else if data == "" {
+		return runners
+	}
+
+	var offline map[string]offlineRunner
+	if err := json.Unmarshal([]byte(data), &offline); err != nil {
+		r.logger.Warn("failed to load offline runners", zap.Error(err))
+		return runners
+	}
+	for name, o := range offline {
+		runners[name] = &reaperRunner{id: o.ID, offlineSince: o.OfflineSince}
+	}
+	r.logger.Info("loaded offline runners", zap.Int("count", len(runners)))
+	return runners
+}
+
+func (r *Reaper) save(ctx context.Context, runners map[string]*reaperRunner) {
+	offline := make(map[string]offlineRunner)
+	for name, runner := range runners {
+		offline[name] = offlineRunner{ID: runner.id, OfflineSince: runner.offlineSince}
+	}
+
+	data, err := json.Marshal(offline)
+	if err != nil {
+		r.logger.Warn("failed to save offline runners", zap.Error(err))
+		return
+	}
+	if err := r.kv.Set(ctx, kvNamespace, r.id, string(data)); err != nil {
+		r.logger.Warn("failed to save offline runners", zap.Error(err))
+	}
+}
+
+// reap deletes runners offline beyond threshold; it returns whether the set of
+// offline runners is changed.
+func (r *Reaper) reap(ctx context.Context, runners map[string]*reaperRunner, state *State, now time.Time) bool {
+	threshold := r.config.GetOfflineThreshold()
+	changed := false
+
+	for name, runner := range runners {
+		if i, ok := state.Instances[name]; !ok || i.ID != runner.id || i.IsOnline {
+			delete(runners, name)
+			changed = true
+		}
+	}
+
+	candidates := 0
+	for name, i := range state.Instances {
+		if i.IsOnline || !r.config.matches(&i) {
+			continue
+		}
+
+		runner, ok := runners[name]
+		if !ok {
+			// Offline duration before being observed is unknown.
+			runner = &reaperRunner{id: i.ID, offlineSince: now}
+			runners[name] = runner
+			changed = true
+		}
+		if now.Sub(runner.offlineSince) < threshold {
+			continue
+		}
+		// Offline runners may still be reported busy until job times out.
+		if i.IsBusy {
+			continue
+		}
+		candidates++
+
+		logger := r.logger.With(
+			zap.Int64("runnerID", i.ID),
+			zap.String("runnerName", i.Name),
+			zap.Time("offlineSince", runner.offlineSince),
+		)
+
+		if r.config.DryRun {
+			if !runner.reported {
+				logger.Info("would delete offline runner")
+				r.record(reaperResultDryRun)
+				runner.reported = true
+			}
+			continue
+		}
+
+		if now.Before(runner.nextRetry) {
+			continue
+		}
+		if err := r.target.DeleteRunner(ctx, i.ID); err != nil {
+			runner.failures++
+			runner.nextRetry = now.Add(runner.retryBackoff())
+			logger.Warn("failed to delete offline runner",
+				zap.Error(err),
+				zap.Int("failures", runner.failures),
+				zap.Time("nextRetry", runner.nextRetry),
+			)
+			r.record(reaperResultFailed)
+			continue
+		}
+		logger.Info("deleted offline runner")
+		r.record(reaperResultDeleted)
+		delete(runners, name)
+		changed = true
+	}
+
+	r.lock.Lock()
+	r.candidates = candidates
+	r.lock.Unlock()
+	return changed
+}
+
+func (r *Reaper) record(result reaperResult) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.results[result]++
+}
+
+func (c *ReaperConfig) matches(i *Instance) bool {
+	if c.matchesAny(i, c.ExcludeNames, c.ExcludeLabels) {
+		return false
+	}
+	if len(c.IncludeNames) == 0 && len(c.IncludeLabels) == 0 {
+		return true
+	}
+	return c.matchesAny(i, c.IncludeNames, c.IncludeLabels)
+}
+
+func (c *ReaperConfig) matchesAny(i *Instance, namePatterns []string, labels []string) bool {
+	for _, p := range namePatterns {
+		if ok, _ := path.Match(p, i.Name); ok {
+			return true
+		}
+	}
+	for _, l := range labels {
+		for _, il := range i.Labels {
+			if l == il {
+				return true
+			}
+		}
+	}
+	return false
+}
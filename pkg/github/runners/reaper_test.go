@@ -0,0 +1,130 @@
+package runners
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/github"
+	"github.com/oursky/github-actions-manager/pkg/github/githubtest"
+	"github.com/oursky/github-actions-manager/pkg/kv"
+
+	gogithub "github.com/google/go-github/v45/github"
+	"github.com/prometheus/client_golang/prometheus"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+)
+
+func TestReaper(t *testing.T) {
+	Convey("Given a reaper", t, func() {
+		ctx := context.Background()
+
+		server := githubtest.NewServer()
+		defer server.Close()
+
+		target := github.NewTargetOrganization(server.Client(), server.URL, "acme")
+		registry := prometheus.NewPedanticRegistry()
+		sync := NewSynchronizer(zap.NewNop(), &Config{}, "acme", target, registry)
+
+		threshold := time.Hour
+		config := &ReaperConfig{
+			Enabled:          true,
+			OfflineThreshold: &threshold,
+			ExcludeNames:     []string{"keep-*"},
+		}
+		store := kv.NewInMemoryStore()
+		reaper := NewReaper(zap.NewNop(), config, sync, store, registry)
+
+		state := &State{Instances: make(map[string]Instance)}
+		for _, r := range []struct {
+			name   string
+			online bool
+			busy   bool
+		}{
+			{"online", true, false},
+			{"offline", false, false},
+			{"offline-busy", false, true},
+			{"keep-offline", false, false},
+		} {
+			runner := server.AddRunner("orgs/acme", &gogithub.Runner{
+				Name:   gogithub.String(r.name),
+				Status: gogithub.String(map[bool]string{true: "online", false: "offline"}[r.online]),
+				Busy:   gogithub.Bool(r.busy),
+			})
+			state.Instances[r.name] = Instance{ID: runner.GetID(), Name: r.name, IsOnline: r.online, IsBusy: r.busy}
+		}
+
+		names := func() []string {
+			var names []string
+			for _, r := range server.Runners("orgs/acme") {
+				names = append(names, r.GetName())
+			}
+			return names
+		}
+
+		runners := make(map[string]*reaperRunner)
+		now := time.Now()
+		So(reaper.reap(ctx, runners, state, now), ShouldBeTrue)
+		So(reaper.reap(ctx, runners, state, now.Add(time.Minute)), ShouldBeFalse)
+
+		Convey("Runners are kept before reaching threshold", func() {
+			reaper.reap(ctx, runners, state, now.Add(30*time.Minute))
+			So(names(), ShouldHaveLength, 4)
+		})
+
+		Convey("Only idle offline runners not excluded are deleted", func() {
+			reaper.reap(ctx, runners, state, now.Add(2*time.Hour))
+			So(names(), ShouldResemble, []string{"online", "offline-busy", "keep-offline"})
+		})
+
+		Convey("Failed deletions are retried with backoff", func() {
+			reaper.target = github.NewTargetOrganization(server.Client(), server.URL, "other")
+			deletes := func() int {
+				count := 0
+				for _, r := range server.Requests() {
+					if r == "DELETE /orgs/other/actions/runners/"+strconv.FormatInt(state.Instances["offline"].ID, 10) {
+						count++
+					}
+				}
+				return count
+			}
+
+			t := now.Add(2 * time.Hour)
+			reaper.reap(ctx, runners, state, t)
+			So(deletes(), ShouldEqual, 1)
+			So(reaper.results[reaperResultFailed], ShouldEqual, 1)
+
+			reaper.reap(ctx, runners, state, t.Add(30*time.Second))
+			So(deletes(), ShouldEqual, 1)
+
+			reaper.reap(ctx, runners, state, t.Add(time.Minute))
+			So(deletes(), ShouldEqual, 2)
+
+			reaper.reap(ctx, runners, state, t.Add(2*time.Minute))
+			So(deletes(), ShouldEqual, 2)
+
+			reaper.reap(ctx, runners, state, t.Add(3*time.Minute))
+			So(deletes(), ShouldEqual, 3)
+		})
+
+		Convey("Offline time is kept across restarts", func() {
+			reaper.save(ctx, runners)
+
+			restarted := NewReaper(zap.NewNop(), config, sync, store, prometheus.NewPedanticRegistry())
+			loaded := restarted.load(ctx)
+			So(loaded, ShouldHaveLength, 2)
+			So(loaded["offline"].offlineSince.Equal(now), ShouldBeTrue)
+
+			restarted.reap(ctx, loaded, state, now.Add(2*time.Hour))
+			So(names(), ShouldResemble, []string{"online", "offline-busy", "keep-offline"})
+		})
+
+		Convey("Runners are not deleted in dry run mode", func() {
+			config.DryRun = true
+			reaper.reap(ctx, runners, state, now.Add(2*time.Hour))
+			So(names(), ShouldHaveLength, 4)
+			So(reaper.results[reaperResultDryRun], ShouldEqual, 1)
+		})
+	})
+}
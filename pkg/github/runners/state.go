@@ -26,6 +26,16 @@ func (i *Instance) labels() prometheus.Labels {
 	return labels
 }
 
+// utilizationLabels returns labels of runner identified by name, since runner
+// ID changes when re-registered.
+func (i *Instance) utilizationLabels() prometheus.Labels {
+	labels := prometheus.Labels{"runner_name": i.Name}
+	for _, l := range i.Labels {
+		labels["runner_label_"+promutil.SanitizeLabel(l)] = l
+	}
+	return labels
+}
+
 type State struct {
 	Epoch     int64
 	Instances map[string]Instance
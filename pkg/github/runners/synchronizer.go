@@ -15,23 +15,37 @@ import (
 type Synchronizer struct {
 	logger  *zap.Logger
 	config  *Config
+	id      string
 	target  github.Target
 	state   *channels.Broadcaster[*State]
+	events  *channels.Broadcaster[*Events]
 	metrics *metrics
+
+	utilization *utilization
+	activities  chan activity
+	overlay     map[string]activity
 }
 
-func NewSynchronizer(logger *zap.Logger, config *Config, target github.Target, registry *prometheus.Registry) *Synchronizer {
+func NewSynchronizer(logger *zap.Logger, config *Config, id string, target github.Target, registry *prometheus.Registry) *Synchronizer {
 	state := &State{Epoch: 0, Instances: nil}
+	utilization := newUtilization(config.GetUtilizationRetention())
 	return &Synchronizer{
-		logger:  logger.Named("runner-sync"),
+		logger:  logger.Named("runner-sync").With(zap.String("target", id)),
 		config:  config,
+		id:      id,
 		target:  target,
 		state:   channels.NewBroadcaster(state),
-		metrics: newMetrics(state, registry),
+		events:  channels.NewBroadcaster(&Events{}),
+		metrics: newMetrics(config.MetricsMode, id, state, utilization, registry),
+
+		utilization: utilization,
+		activities:  make(chan activity, activityQueueSize),
+		overlay:     make(map[string]activity),
 	}
 }
 
 func (s *Synchronizer) Start(ctx context.Context, g *errgroup.Group) error {
+	ctx = github.WithModule(ctx, "runner-sync")
 	g.Go(func() error {
 		s.run(ctx)
 		return nil
@@ -39,10 +53,24 @@ func (s *Synchronizer) Start(ctx context.Context, g *errgroup.Group) error {
 	return nil
 }
 
+func (s *Synchronizer) ID() string {
+	return s.id
+}
+
 func (s *Synchronizer) State() *channels.Broadcaster[*State] {
 	return s.state
 }
 
+// Events publishes changes of runners between epochs.
+func (s *Synchronizer) Events() *channels.Broadcaster[*Events] {
+	return s.events
+}
+
+// Utilization returns utilization of runners over the window.
+func (s *Synchronizer) Utilization(window time.Duration) *Utilization {
+	return s.utilization.query(window, time.Now())
+}
+
 func (s *Synchronizer) run(ctx context.Context) {
 	syncInterval := s.config.GetSyncInterval()
 
@@ -52,21 +80,43 @@ func (s *Synchronizer) run(ctx context.Context) {
 	for {
 		state := work.do(ctx)
 		if state != nil {
-			s.state.Publish(state)
-			s.metrics.update(state)
+			s.applyOverlay(state, work.beginTime)
+			s.publish(state)
 			work.reset(state.Epoch + 1)
 		}
 
-		select {
-		case <-ctx.Done():
-			return
-
-		case <-time.After(syncInterval):
-			continue
+		next := time.After(syncInterval)
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case a := <-s.activities:
+				// Epoch is not bumped, since the state is not polled.
+				if state := s.applyActivity(s.state.Value(), a); state != nil {
+					s.publish(state)
+				}
+
+			case <-next:
+				break wait
+			}
 		}
 	}
 }
 
+func (s *Synchronizer) publish(state *State) {
+	now := time.Now()
+	s.utilization.observe(state, now)
+	events := diffStates(s.state.Value(), state, now)
+	if len(events) > 0 {
+		s.events.Publish(s.events.Value().append(events))
+	}
+	s.state.Publish(state)
+	s.metrics.update(state)
+	s.metrics.observeEvents(events)
+}
+
 type syncWork struct {
 	*Synchronizer
 	pageSize int
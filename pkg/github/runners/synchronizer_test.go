@@ -0,0 +1,143 @@
+package runners
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/oursky/github-actions-manager/pkg/github"
+	"github.com/oursky/github-actions-manager/pkg/github/githubtest"
+
+	gogithub "github.com/google/go-github/v45/github"
+	"github.com/prometheus/client_golang/prometheus"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestSynchronizer(t *testing.T) {
+	Convey("Given a synchronizer of an organization", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := githubtest.NewServer()
+		defer server.Close()
+
+		for _, name := range []string{"runner-1", "runner-2", "runner-3"} {
+			server.AddRunner("orgs/acme", &gogithub.Runner{Name: gogithub.String(name)})
+		}
+		server.AddRunner("orgs/acme", &gogithub.Runner{
+			Name:   gogithub.String("runner-4"),
+			Status: gogithub.String("offline"),
+		})
+
+		target := github.NewTargetOrganization(server.Client(), server.URL, "acme")
+		interval := 10 * time.Millisecond
+		pageSize := 2
+		sync := NewSynchronizer(
+			zap.NewNop(),
+			&Config{SyncInterval: &interval, SyncPageSize: &pageSize},
+			"acme",
+			target,
+			prometheus.NewPedanticRegistry(),
+		)
+
+		g, ctx := errgroup.WithContext(ctx)
+		So(sync.Start(ctx, g), ShouldBeNil)
+
+		Convey("Runners across pages are synchronized", func() {
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				return len(sync.State().Value().Instances) == 4
+			})
+			So(ok, ShouldBeTrue)
+
+			state := sync.State().Value()
+			So(state.Instances["runner-1"].IsOnline, ShouldBeTrue)
+			So(state.Instances["runner-4"].IsOnline, ShouldBeFalse)
+		})
+
+		Convey("Deleted runners are removed in later epochs", func() {
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				return len(sync.State().Value().Instances) == 4
+			})
+			So(ok, ShouldBeTrue)
+
+			id := sync.State().Value().Instances["runner-2"].ID
+			So(target.DeleteRunner(ctx, id), ShouldBeNil)
+
+			ok = githubtest.Eventually(5*time.Second, func() bool {
+				_, found := sync.State().Value().Lookup("runner-2", id)
+				return !found
+			})
+			So(ok, ShouldBeTrue)
+			So(len(sync.State().Value().Instances), ShouldEqual, 3)
+
+			var removed *Event
+			for _, e := range sync.Events().Value().Events {
+				if e.Type == EventRemoved && e.Runner.ID == id {
+					e := e
+					removed = &e
+				}
+			}
+			So(removed, ShouldNotBeNil)
+			So(removed.Epoch, ShouldBeGreaterThan, 1)
+		})
+
+		Convey("Busy state is updated from webhook between epochs", func() {
+			longInterval := time.Hour
+			sync := NewSynchronizer(
+				zap.NewNop(),
+				&Config{SyncInterval: &longInterval},
+				"acme",
+				target,
+				prometheus.NewPedanticRegistry(),
+			)
+			So(sync.Start(ctx, g), ShouldBeNil)
+
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				return len(sync.State().Value().Instances) == 4
+			})
+			So(ok, ShouldBeTrue)
+
+			runner := sync.State().Value().Instances["runner-1"]
+			epoch := sync.State().Value().Epoch
+			sync.ObserveWorkflowJob("in_progress", &gogithub.WorkflowJob{
+				RunnerID:   gogithub.Int64(runner.ID),
+				RunnerName: gogithub.String(runner.Name),
+				StartedAt:  &gogithub.Timestamp{Time: time.Now()},
+			})
+
+			ok = githubtest.Eventually(5*time.Second, func() bool {
+				return sync.State().Value().Instances["runner-1"].IsBusy
+			})
+			So(ok, ShouldBeTrue)
+			So(sync.State().Value().Epoch, ShouldEqual, epoch)
+		})
+
+		Convey("Changes of runners are published as events", func() {
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				return len(sync.Events().Value().Events) == 4
+			})
+			So(ok, ShouldBeTrue)
+			for _, e := range sync.Events().Value().Events {
+				So(e.Type, ShouldEqual, EventAdded)
+			}
+			seq := sync.Events().Value().Events[3].Seq
+
+			runner := server.Runners("orgs/acme")[0]
+			server.UpdateRunner("orgs/acme", runner.GetID(), func(r *gogithub.Runner) {
+				r.Busy = gogithub.Bool(true)
+			})
+
+			ok = githubtest.Eventually(5*time.Second, func() bool {
+				return len(sync.Events().Value().Since(seq)) == 1
+			})
+			So(ok, ShouldBeTrue)
+
+			event := sync.Events().Value().Since(seq)[0]
+			So(event.Type, ShouldEqual, EventBusy)
+			So(event.Runner.Name, ShouldEqual, runner.GetName())
+			So(event.ObservedAt.IsZero(), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,257 @@
+package runners
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const utilizationBucketSize = time.Hour
+
+type runnerStatus int
+
+const (
+	statusOffline runnerStatus = iota
+	statusIdle
+	statusBusy
+)
+
+func (i *Instance) status() runnerStatus {
+	switch {
+	case !i.IsOnline:
+		return statusOffline
+	case i.IsBusy:
+		return statusBusy
+	default:
+		return statusIdle
+	}
+}
+
+type utilizationTimes struct {
+	Busy    time.Duration
+	Idle    time.Duration
+	Offline time.Duration
+}
+
+func (t *utilizationTimes) add(status runnerStatus, d time.Duration) {
+	switch status {
+	case statusBusy:
+		t.Busy += d
+	case statusIdle:
+		t.Idle += d
+	case statusOffline:
+		t.Offline += d
+	}
+}
+
+type utilizationBucket struct {
+	start time.Time
+	utilizationTimes
+}
+
+type utilizationSeries struct {
+	labels       []string
+	lastObserved time.Time
+	total        utilizationTimes
+	buckets      []utilizationBucket
+
+	// removed is set when the runner is no longer registered; its total is
+	// exported once more before its metric series is dropped.
+	removed       bool
+	totalExported bool
+}
+
+func (s *utilizationSeries) add(status runnerStatus, d time.Duration, now time.Time) {
+	s.lastObserved = now
+	s.total.add(status, d)
+
+	start := now.Truncate(utilizationBucketSize)
+	if len(s.buckets) == 0 || s.buckets[len(s.buckets)-1].start != start {
+		s.buckets = append(s.buckets, utilizationBucket{start: start})
+	}
+	s.buckets[len(s.buckets)-1].add(status, d)
+}
+
+func (s *utilizationSeries) sum(since time.Time) utilizationTimes {
+	var t utilizationTimes
+	for _, b := range s.buckets {
+		if b.start.Before(since) {
+			continue
+		}
+		t.Busy += b.Busy
+		t.Idle += b.Idle
+		t.Offline += b.Offline
+	}
+	return t
+}
+
+// UtilizationStats is the time spent by a runner or a pool of runners with
+// the same labels.
+type UtilizationStats struct {
+	RunnerName     string   `json:"runnerName,omitempty"`
+	Labels         []string `json:"labels"`
+	BusySeconds    float64  `json:"busySeconds"`
+	IdleSeconds    float64  `json:"idleSeconds"`
+	OfflineSeconds float64  `json:"offlineSeconds"`
+	// Utilization is the fraction of online time that runners are busy.
+	Utilization float64 `json:"utilization"`
+}
+
+func newUtilizationStats(name string, labels []string, t utilizationTimes) UtilizationStats {
+	stats := UtilizationStats{
+		RunnerName:     name,
+		Labels:         labels,
+		BusySeconds:    t.Busy.Seconds(),
+		IdleSeconds:    t.Idle.Seconds(),
+		OfflineSeconds: t.Offline.Seconds(),
+	}
+	if online := t.Busy + t.Idle; online > 0 {
+		stats.Utilization = float64(t.Busy) / float64(online)
+	}
+	return stats
+}
+
+type Utilization struct {
+	Since     time.Time          `json:"since"`
+	Runners   []UtilizationStats `json:"runners"`
+	LabelSets []UtilizationStats `json:"labelSets"`
+}
+
+// utilization accounts time spent in each status by runners and label sets,
+// in hourly buckets.
+type utilization struct {
+	lock      *sync.RWMutex
+	retention time.Duration
+
+	prev      *State
+	prevTime  time.Time
+	runners   map[string]*utilizationSeries
+	labelSets map[string]*utilizationSeries
+}
+
+func newUtilization(retention time.Duration) *utilization {
+	return &utilization{
+		lock:      new(sync.RWMutex),
+		retention: retention,
+		runners:   make(map[string]*utilizationSeries),
+		labelSets: make(map[string]*utilizationSeries),
+	}
+}
+
+func labelSetKey(labels []string) []string {
+	sorted := append([]string(nil), labels...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// observe attributes time since last observation to status of runners in
+// last observed state.
+func (u *utilization) observe(state *State, now time.Time) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	if u.prev != nil {
+		elapsed := now.Sub(u.prevTime)
+		for name, i := range u.prev.Instances {
+			status := i.status()
+
+			series, ok := u.runners[name]
+			if !ok {
+				series = &utilizationSeries{}
+				u.runners[name] = series
+			}
+			series.labels = i.Labels
+			series.add(status, elapsed, now)
+
+			labels := labelSetKey(i.Labels)
+			key := strings.Join(labels, ",")
+			series, ok = u.labelSets[key]
+			if !ok {
+				series = &utilizationSeries{labels: labels}
+				u.labelSets[key] = series
+			}
+			series.add(status, elapsed, now)
+		}
+	}
+	u.prev = state
+	u.prevTime = now
+
+	for name, series := range u.runners {
+		_, ok := state.Instances[name]
+		series.removed = !ok
+		if ok {
+			series.totalExported = false
+		}
+	}
+
+	u.prune(now)
+}
+
+func (u *utilization) prune(now time.Time) {
+	limit := now.Add(-u.retention)
+	for _, m := range []map[string]*utilizationSeries{u.runners, u.labelSets} {
+		for key, series := range m {
+			if series.lastObserved.Before(limit) {
+				delete(m, key)
+				continue
+			}
+			i := 0
+			for i < len(series.buckets) && series.buckets[i].start.Add(utilizationBucketSize).Before(limit) {
+				i++
+			}
+			series.buckets = series.buckets[i:]
+		}
+	}
+}
+
+// query returns utilization over the window, rounded to hourly buckets.
+func (u *utilization) query(window time.Duration, now time.Time) *Utilization {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	since := now.Add(-window).Truncate(utilizationBucketSize)
+	result := &Utilization{Since: since, Runners: []UtilizationStats{}, LabelSets: []UtilizationStats{}}
+	for name, series := range u.runners {
+		result.Runners = append(result.Runners, newUtilizationStats(name, series.labels, series.sum(since)))
+	}
+	for _, series := range u.labelSets {
+		result.LabelSets = append(result.LabelSets, newUtilizationStats("", series.labels, series.sum(since)))
+	}
+
+	sort.Slice(result.Runners, func(i, j int) bool {
+		return result.Runners[i].RunnerName < result.Runners[j].RunnerName
+	})
+	sort.Slice(result.LabelSets, func(i, j int) bool {
+		return strings.Join(result.LabelSets[i].Labels, ",") < strings.Join(result.LabelSets[j].Labels, ",")
+	})
+	return result
+}
+
+type utilizationTotal struct {
+	labels []string
+	utilizationTimes
+}
+
+// totals returns total time by runner name and by label set. Removed runners
+// are returned only once, so that per-runner series of ephemeral runners do
+// not accumulate.
+func (u *utilization) totals() (runners map[string]utilizationTotal, labelSets []utilizationTotal) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	runners = make(map[string]utilizationTotal, len(u.runners))
+	for name, series := range u.runners {
+		if series.removed {
+			if series.totalExported {
+				continue
+			}
+			series.totalExported = true
+		}
+		runners[name] = utilizationTotal{labels: series.labels, utilizationTimes: series.total}
+	}
+	for _, series := range u.labelSets {
+		labelSets = append(labelSets, utilizationTotal{labels: series.labels, utilizationTimes: series.total})
+	}
+	return runners, labelSets
+}
@@ -0,0 +1,79 @@
+package runners
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUtilization(t *testing.T) {
+	Convey("Given runner utilization accounting", t, func() {
+		u := newUtilization(24 * time.Hour)
+		now := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+
+		observe := func(after time.Duration, instances ...Instance) {
+			state := &State{Instances: make(map[string]Instance)}
+			for _, i := range instances {
+				state.Instances[i.Name] = i
+			}
+			now = now.Add(after)
+			u.observe(state, now)
+		}
+
+		observe(0,
+			Instance{Name: "a", IsOnline: true, IsBusy: true, Labels: []string{"x64", "linux"}},
+			Instance{Name: "b", IsOnline: true, Labels: []string{"linux", "x64"}},
+		)
+		observe(10*time.Minute,
+			Instance{Name: "a", IsOnline: true, Labels: []string{"x64", "linux"}},
+			Instance{Name: "b", IsOnline: false, Labels: []string{"linux", "x64"}},
+		)
+		observe(20 * time.Minute)
+
+		Convey("Time is attributed to last observed status", func() {
+			result := u.query(time.Hour, now)
+			So(result.Runners, ShouldHaveLength, 2)
+
+			a := result.Runners[0]
+			So(a.RunnerName, ShouldEqual, "a")
+			So(a.BusySeconds, ShouldEqual, 600)
+			So(a.IdleSeconds, ShouldEqual, 1200)
+			So(a.Utilization, ShouldAlmostEqual, 1.0/3)
+
+			b := result.Runners[1]
+			So(b.IdleSeconds, ShouldEqual, 600)
+			So(b.OfflineSeconds, ShouldEqual, 1200)
+		})
+
+		Convey("Runners with same labels are grouped", func() {
+			result := u.query(time.Hour, now)
+			So(result.LabelSets, ShouldHaveLength, 1)
+			So(result.LabelSets[0].Labels, ShouldResemble, []string{"linux", "x64"})
+			So(result.LabelSets[0].BusySeconds, ShouldEqual, 600)
+			So(result.LabelSets[0].IdleSeconds, ShouldEqual, 1800)
+			So(result.LabelSets[0].OfflineSeconds, ShouldEqual, 1200)
+		})
+
+		Convey("Totals of removed runners are returned once", func() {
+			runners, labelSets := u.totals()
+			So(runners, ShouldHaveLength, 2)
+			So(runners["a"].Busy, ShouldEqual, 10*time.Minute)
+			So(labelSets, ShouldHaveLength, 1)
+
+			runners, labelSets = u.totals()
+			So(runners, ShouldBeEmpty)
+			So(labelSets, ShouldHaveLength, 1)
+
+			observe(time.Minute, Instance{Name: "a", IsOnline: true, Labels: []string{"x64", "linux"}})
+			runners, _ = u.totals()
+			So(runners, ShouldHaveLength, 1)
+			So(runners["a"].Idle, ShouldEqual, 20*time.Minute)
+		})
+
+		Convey("History outside window is excluded", func() {
+			result := u.query(time.Hour, now.Add(3*time.Hour))
+			So(result.Runners[0].BusySeconds, ShouldEqual, 0)
+		})
+	})
+}
@@ -2,8 +2,9 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
+	"net/url"
 	"regexp"
 
 	"github.com/google/go-github/v45/github"
@@ -14,24 +15,52 @@ type Target interface {
 	GetRegistrationToken(ctx context.Context) (*github.RegistrationToken, error)
 	GetRunners(ctx context.Context, page int, pageSize int) (runners []*github.Runner, nextPage int, err error)
 	DeleteRunner(ctx context.Context, id int64) error
+	GenerateJITConfig(ctx context.Context, name string, group string, labels []string, workFolder string) (*JITConfig, error)
+
+	ListRunnerGroups(ctx context.Context) ([]*github.RunnerGroup, error)
+	CreateRunnerGroup(ctx context.Context, req github.CreateRunnerGroupRequest) (*github.RunnerGroup, error)
+	UpdateRunnerGroup(ctx context.Context, id int64, req github.UpdateRunnerGroupRequest) (*github.RunnerGroup, error)
+	ListRunnerGroupRepositories(ctx context.Context, id int64) ([]*github.Repository, error)
+	SetRunnerGroupRepositories(ctx context.Context, id int64, repositoryIDs []int64) error
+
+	ListRunnerLabels(ctx context.Context, id int64) ([]*github.RunnerLabels, error)
+	AddRunnerLabels(ctx context.Context, id int64, labels []string) ([]*github.RunnerLabels, error)
+	SetRunnerLabels(ctx context.Context, id int64, labels []string) ([]*github.RunnerLabels, error)
+	RemoveRunnerLabel(ctx context.Context, id int64, label string) ([]*github.RunnerLabels, error)
 }
 
+// ErrUnsupported is returned when an operation is not available for the
+// kind of target.
+var ErrUnsupported = errors.New("operation not supported by target")
+
 var (
-	regexTargetRepo = regexp.MustCompile(`https://github\.com/([^/]+)/([^/]+)/?`)
-	regexTargetOrg  = regexp.MustCompile(`https://github\.com/([^/]+)/?`)
+	regexTargetEnterprise = regexp.MustCompile(`^/enterprises/([^/]+)/?$`)
+	regexTargetRepo       = regexp.MustCompile(`^/([^/]+)/([^/]+)/?$`)
+	regexTargetOrg        = regexp.MustCompile(`^/([^/]+)/?$`)
 )
 
-func NewTarget(http *http.Client, url string) (Target, error) {
-	if match := regexTargetRepo.FindStringSubmatch(url); match != nil {
+func NewTarget(client *github.Client, targetURL string) (Target, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub target URL: %w", err)
+	}
+	baseURL := u.Scheme + "://" + u.Host
+
+	if match := regexTargetEnterprise.FindStringSubmatch(u.Path); match != nil {
+		slug := match[1]
+		return NewTargetEnterprise(client, baseURL, slug), nil
+	}
+
+	if match := regexTargetRepo.FindStringSubmatch(u.Path); match != nil {
 		owner := match[1]
 		name := match[2]
-		return NewTargetRepository(http, name, owner), nil
+		return NewTargetRepository(client, baseURL, name, owner), nil
 	}
 
-	if match := regexTargetOrg.FindStringSubmatch(url); match != nil {
+	if match := regexTargetOrg.FindStringSubmatch(u.Path); match != nil {
 		name := match[1]
-		return NewTargetOrganization(http, name), nil
+		return NewTargetOrganization(client, baseURL, name), nil
 	}
 
-	return nil, fmt.Errorf("unsupported GitHub target URL: %s", url)
+	return nil, fmt.Errorf("unsupported GitHub target URL: %s", targetURL)
 }
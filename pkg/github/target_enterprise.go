@@ -0,0 +1,176 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v45/github"
+)
+
+type TargetEnterprise struct {
+	client  *github.Client
+	baseURL string
+
+	Slug string
+}
+
+func NewTargetEnterprise(client *github.Client, baseURL string, slug string) *TargetEnterprise {
+	return &TargetEnterprise{client: client, baseURL: baseURL, Slug: slug}
+}
+
+func (t *TargetEnterprise) URL() string {
+	return fmt.Sprintf("%s/enterprises/%s", t.baseURL, t.Slug)
+}
+
+func (t *TargetEnterprise) GetRegistrationToken(ctx context.Context) (*github.RegistrationToken, error) {
+	ctx = WithAdminCredential(ctx)
+	token, _, err := t.client.Enterprise.CreateRegistrationToken(ctx, t.Slug)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (t *TargetEnterprise) GetRunners(
+	ctx context.Context, page int, pageSize int,
+) ([]*github.Runner, int, error) {
+	runners, resp, err := t.client.Enterprise.ListRunners(
+		ctx, t.Slug,
+		&github.ListOptions{Page: page, PerPage: pageSize},
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return runners.Runners, resp.NextPage, nil
+}
+
+func (t *TargetEnterprise) DeleteRunner(ctx context.Context, id int64) error {
+	ctx = WithAdminCredential(ctx)
+	_, err := t.client.Enterprise.RemoveRunner(ctx, t.Slug, id)
+	return err
+}
+
+func (t *TargetEnterprise) GenerateJITConfig(
+	ctx context.Context, name string, group string, labels []string, workFolder string,
+) (*JITConfig, error) {
+	groupID, err := t.getRunnerGroupID(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	return generateJITConfig(
+		ctx, t.client,
+		t.actionsPath()+"/runners/generate-jitconfig",
+		newJITConfigRequest(name, groupID, labels, workFolder),
+	)
+}
+
+func (t *TargetEnterprise) getRunnerGroupID(ctx context.Context, name string) (int64, error) {
+	if name == "" {
+		return defaultRunnerGroupID, nil
+	}
+
+	groups, err := t.ListRunnerGroups(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return findRunnerGroupID(groups, name)
+}
+
+func (t *TargetEnterprise) ListRunnerGroups(ctx context.Context) ([]*github.RunnerGroup, error) {
+	var groups []*github.RunnerGroup
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		u, err := addOptions(fmt.Sprintf("enterprises/%s/actions/runner-groups", t.Slug), opts)
+		if err != nil {
+			return nil, err
+		}
+		r, err := t.client.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		page := new(github.RunnerGroups)
+		resp, err := t.client.Do(ctx, r, page)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, page.RunnerGroups...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return groups, nil
+}
+
+func (t *TargetEnterprise) CreateRunnerGroup(
+	ctx context.Context, req github.CreateRunnerGroupRequest,
+) (*github.RunnerGroup, error) {
+	ctx = WithAdminCredential(ctx)
+	// Enterprise runner groups grant access to organizations, not repositories.
+	if len(req.SelectedRepositoryIDs) > 0 {
+		return nil, ErrUnsupported
+	}
+
+	r, err := t.client.NewRequest("POST", fmt.Sprintf("enterprises/%s/actions/runner-groups", t.Slug), req)
+	if err != nil {
+		return nil, err
+	}
+
+	group := new(github.RunnerGroup)
+	if _, err := t.client.Do(ctx, r, group); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+func (t *TargetEnterprise) UpdateRunnerGroup(
+	ctx context.Context, id int64, req github.UpdateRunnerGroupRequest,
+) (*github.RunnerGroup, error) {
+	ctx = WithAdminCredential(ctx)
+	r, err := t.client.NewRequest("PATCH", fmt.Sprintf("enterprises/%s/actions/runner-groups/%d", t.Slug, id), req)
+	if err != nil {
+		return nil, err
+	}
+
+	group := new(github.RunnerGroup)
+	if _, err := t.client.Do(ctx, r, group); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+func (t *TargetEnterprise) ListRunnerGroupRepositories(ctx context.Context, id int64) ([]*github.Repository, error) {
+	return nil, ErrUnsupported
+}
+
+func (t *TargetEnterprise) SetRunnerGroupRepositories(ctx context.Context, id int64, repositoryIDs []int64) error {
+	return ErrUnsupported
+}
+
+func (t *TargetEnterprise) actionsPath() string {
+	return fmt.Sprintf("enterprises/%s/actions", t.Slug)
+}
+
+func (t *TargetEnterprise) ListRunnerLabels(ctx context.Context, id int64) ([]*github.RunnerLabels, error) {
+	return listRunnerLabels(ctx, t.client, t.actionsPath(), id)
+}
+
+func (t *TargetEnterprise) AddRunnerLabels(ctx context.Context, id int64, labels []string) ([]*github.RunnerLabels, error) {
+	return addRunnerLabels(ctx, t.client, t.actionsPath(), id, labels)
+}
+
+func (t *TargetEnterprise) SetRunnerLabels(ctx context.Context, id int64, labels []string) ([]*github.RunnerLabels, error) {
+	return setRunnerLabels(ctx, t.client, t.actionsPath(), id, labels)
+}
+
+func (t *TargetEnterprise) RemoveRunnerLabel(ctx context.Context, id int64, label string) ([]*github.RunnerLabels, error) {
+	return removeRunnerLabel(ctx, t.client, t.actionsPath(), id, label)
+}
@@ -3,27 +3,27 @@ package github
 import (
 	"context"
 	"fmt"
-	"net/http"
 
 	"github.com/google/go-github/v45/github"
 )
 
 type TargetOrganization struct {
-	client *github.Client
+	client  *github.Client
+	baseURL string
 
 	Name string
 }
 
-func NewTargetOrganization(http *http.Client, name string) *TargetOrganization {
-	client := github.NewClient(http)
-	return &TargetOrganization{client: client, Name: name}
+func NewTargetOrganization(client *github.Client, baseURL string, name string) *TargetOrganization {
+	return &TargetOrganization{client: client, baseURL: baseURL, Name: name}
 }
 
 func (t *TargetOrganization) URL() string {
-	return fmt.Sprintf("https://github.com/%s", t.Name)
+	return fmt.Sprintf("%s/%s", t.baseURL, t.Name)
 }
 
 func (t *TargetOrganization) GetRegistrationToken(ctx context.Context) (*github.RegistrationToken, error) {
+	ctx = WithAdminCredential(ctx)
 	token, _, err := t.client.Actions.CreateOrganizationRegistrationToken(ctx, t.Name)
 	if err != nil {
 		return nil, err
@@ -47,6 +47,128 @@ func (t *TargetOrganization) GetRunners(
 }
 
 func (t *TargetOrganization) DeleteRunner(ctx context.Context, id int64) error {
+	ctx = WithAdminCredential(ctx)
 	_, err := t.client.Actions.RemoveOrganizationRunner(ctx, t.Name, id)
 	return err
 }
+
+func (t *TargetOrganization) GenerateJITConfig(
+	ctx context.Context, name string, group string, labels []string, workFolder string,
+) (*JITConfig, error) {
+	groupID, err := t.getRunnerGroupID(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	return generateJITConfig(
+		ctx, t.client,
+		t.actionsPath()+"/runners/generate-jitconfig",
+		newJITConfigRequest(name, groupID, labels, workFolder),
+	)
+}
+
+func (t *TargetOrganization) getRunnerGroupID(ctx context.Context, name string) (int64, error) {
+	if name == "" {
+		return defaultRunnerGroupID, nil
+	}
+
+	groups, err := t.ListRunnerGroups(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return findRunnerGroupID(groups, name)
+}
+
+func (t *TargetOrganization) ListRunnerGroups(ctx context.Context) ([]*github.RunnerGroup, error) {
+	var groups []*github.RunnerGroup
+	opts := &github.ListOrgRunnerGroupOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := t.client.Actions.ListOrganizationRunnerGroups(ctx, t.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, page.RunnerGroups...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return groups, nil
+}
+
+func (t *TargetOrganization) CreateRunnerGroup(
+	ctx context.Context, req github.CreateRunnerGroupRequest,
+) (*github.RunnerGroup, error) {
+	ctx = WithAdminCredential(ctx)
+	group, _, err := t.client.Actions.CreateOrganizationRunnerGroup(ctx, t.Name, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+func (t *TargetOrganization) UpdateRunnerGroup(
+	ctx context.Context, id int64, req github.UpdateRunnerGroupRequest,
+) (*github.RunnerGroup, error) {
+	ctx = WithAdminCredential(ctx)
+	group, _, err := t.client.Actions.UpdateOrganizationRunnerGroup(ctx, t.Name, id, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
+
+func (t *TargetOrganization) ListRunnerGroupRepositories(ctx context.Context, id int64) ([]*github.Repository, error) {
+	var repos []*github.Repository
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := t.client.Actions.ListRepositoryAccessRunnerGroup(ctx, t.Name, id, opts)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, page.Repositories...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return repos, nil
+}
+
+func (t *TargetOrganization) SetRunnerGroupRepositories(ctx context.Context, id int64, repositoryIDs []int64) error {
+	ctx = WithAdminCredential(ctx)
+	if repositoryIDs == nil {
+		repositoryIDs = []int64{}
+	}
+	_, err := t.client.Actions.SetRepositoryAccessRunnerGroup(
+		ctx, t.Name, id,
+		github.SetRepoAccessRunnerGroupRequest{SelectedRepositoryIDs: repositoryIDs},
+	)
+	return err
+}
+
+func (t *TargetOrganization) actionsPath() string {
+	return fmt.Sprintf("orgs/%s/actions", t.Name)
+}
+
+func (t *TargetOrganization) ListRunnerLabels(ctx context.Context, id int64) ([]*github.RunnerLabels, error) {
+	return listRunnerLabels(ctx, t.client, t.actionsPath(), id)
+}
+
+func (t *TargetOrganization) AddRunnerLabels(ctx context.Context, id int64, labels []string) ([]*github.RunnerLabels, error) {
+	return addRunnerLabels(ctx, t.client, t.actionsPath(), id, labels)
+}
+
+func (t *TargetOrganization) SetRunnerLabels(ctx context.Context, id int64, labels []string) ([]*github.RunnerLabels, error) {
+	return setRunnerLabels(ctx, t.client, t.actionsPath(), id, labels)
+}
+
+func (t *TargetOrganization) RemoveRunnerLabel(ctx context.Context, id int64, label string) ([]*github.RunnerLabels, error) {
+	return removeRunnerLabel(ctx, t.client, t.actionsPath(), id, label)
+}
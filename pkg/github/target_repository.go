@@ -3,28 +3,28 @@ package github
 import (
 	"context"
 	"fmt"
-	"net/http"
 
 	"github.com/google/go-github/v45/github"
 )
 
 type TargetRepository struct {
-	client *github.Client
+	client  *github.Client
+	baseURL string
 
 	Name  string
 	Owner string
 }
 
-func NewTargetRepository(http *http.Client, name string, owner string) *TargetRepository {
-	client := github.NewClient(http)
-	return &TargetRepository{client: client, Name: name, Owner: owner}
+func NewTargetRepository(client *github.Client, baseURL string, name string, owner string) *TargetRepository {
+	return &TargetRepository{client: client, baseURL: baseURL, Name: name, Owner: owner}
 }
 
 func (t *TargetRepository) URL() string {
-	return fmt.Sprintf("https://github.com/%s/%s", t.Owner, t.Name)
+	return fmt.Sprintf("%s/%s/%s", t.baseURL, t.Owner, t.Name)
 }
 
 func (t *TargetRepository) GetRegistrationToken(ctx context.Context) (*github.RegistrationToken, error) {
+	ctx = WithAdminCredential(ctx)
 	token, _, err := t.client.Actions.CreateRegistrationToken(ctx, t.Owner, t.Name)
 	if err != nil {
 		return nil, err
@@ -48,6 +48,65 @@ func (t *TargetRepository) GetRunners(
 }
 
 func (t *TargetRepository) DeleteRunner(ctx context.Context, id int64) error {
+	ctx = WithAdminCredential(ctx)
 	_, err := t.client.Actions.RemoveRunner(ctx, t.Owner, t.Name, id)
 	return err
 }
+
+func (t *TargetRepository) GenerateJITConfig(
+	ctx context.Context, name string, group string, labels []string, workFolder string,
+) (*JITConfig, error) {
+	if group != "" {
+		return nil, fmt.Errorf("runner group is not supported for repository target: %w", ErrUnsupported)
+	}
+
+	return generateJITConfig(
+		ctx, t.client,
+		t.actionsPath()+"/runners/generate-jitconfig",
+		newJITConfigRequest(name, defaultRunnerGroupID, labels, workFolder),
+	)
+}
+
+func (t *TargetRepository) ListRunnerGroups(ctx context.Context) ([]*github.RunnerGroup, error) {
+	return nil, ErrUnsupported
+}
+
+func (t *TargetRepository) CreateRunnerGroup(
+	ctx context.Context, req github.CreateRunnerGroupRequest,
+) (*github.RunnerGroup, error) {
+	return nil, ErrUnsupported
+}
+
+func (t *TargetRepository) UpdateRunnerGroup(
+	ctx context.Context, id int64, req github.UpdateRunnerGroupRequest,
+) (*github.RunnerGroup, error) {
+	return nil, ErrUnsupported
+}
+
+func (t *TargetRepository) ListRunnerGroupRepositories(ctx context.Context, id int64) ([]*github.Repository, error) {
+	return nil, ErrUnsupported
+}
+
+func (t *TargetRepository) SetRunnerGroupRepositories(ctx context.Context, id int64, repositoryIDs []int64) error {
+	return ErrUnsupported
+}
+
+func (t *TargetRepository) actionsPath() string {
+	return fmt.Sprintf("repos/%s/%s/actions", t.Owner, t.Name)
+}
+
+func (t *TargetRepository) ListRunnerLabels(ctx context.Context, id int64) ([]*github.RunnerLabels, error) {
+	return listRunnerLabels(ctx, t.client, t.actionsPath(), id)
+}
+
+func (t *TargetRepository) AddRunnerLabels(ctx context.Context, id int64, labels []string) ([]*github.RunnerLabels, error) {
+	return addRunnerLabels(ctx, t.client, t.actionsPath(), id, labels)
+}
+
+func (t *TargetRepository) SetRunnerLabels(ctx context.Context, id int64, labels []string) ([]*github.RunnerLabels, error) {
+	return setRunnerLabels(ctx, t.client, t.actionsPath(), id, labels)
+}
+
+func (t *TargetRepository) RemoveRunnerLabel(ctx context.Context, id int64, label string) ([]*github.RunnerLabels, error) {
+	return removeRunnerLabel(ctx, t.client, t.actionsPath(), id, label)
+}
@@ -27,6 +27,7 @@ const (
 	labelRunner            = "github-actions-manager.oursky.com/runner"
 	annotationRunnerGroup  = "github-actions-manager.oursky.com/runner-group"
 	annotationRunnerLabels = "github-actions-manager.oursky.com/runner-labels"
+	annotationRunnerRepos  = "github-actions-manager.oursky.com/runner-repositories"
 	annotationRunnerState  = "github-actions-manager.oursky.com/runner-state"
 	annotationBusy         = "github-actions-manager.oursky.com/busy"
 	finalizer              = "github-actions-manager.oursky.com/finalizer"
@@ -119,13 +120,7 @@ func (p *ControllerProvider) AuthenticateRequest(rw http.ResponseWriter, r *http
 	next.ServeHTTP(rw, r)
 }
 
-func (p *ControllerProvider) RegisterAgent(
-	r *http.Request,
-	hostName string,
-	regToken string,
-	targetURL string,
-	disableUpdate bool,
-) (*controller.AgentResponse, error) {
+func (p *ControllerProvider) RegisterAgent(r *http.Request, hostName string) (*controller.AgentResponse, error) {
 	pod := r.Context().Value(authzContextKey).(*corev1.Pod)
 	annotations := pod.Annotations
 	group := annotations[annotationRunnerGroup]
@@ -151,20 +146,22 @@ func (p *ControllerProvider) RegisterAgent(
 	p.logger.Info("registered agent",
 		zap.String("id", agent.ID),
 		zap.String("runnerName", agent.RunnerName),
-		zap.String("url", targetURL),
 		zap.String("group", group),
 		zap.Strings("labels", labels),
 	)
 
 	p.updateAgentPod(p.ctx, pod, agent.RunnerName, false)
 
+	var repos []string
+	if v := annotations[annotationRunnerRepos]; v != "" {
+		repos = strings.Split(v, ",")
+	}
+
 	return &controller.AgentResponse{
-		Agent:         *agent,
-		TargetURL:     targetURL,
-		Token:         regToken,
-		Group:         group,
-		Labels:        labels,
-		DisableUpdate: &disableUpdate,
+		Agent:        *agent,
+		Group:        group,
+		Labels:       labels,
+		Repositories: repos,
 	}, nil
 }
 
@@ -3,9 +3,11 @@ package slack
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/go-github/v45/github"
+	gh "github.com/oursky/github-actions-manager/pkg/github"
 	"github.com/oursky/github-actions-manager/pkg/github/jobs"
 	"github.com/oursky/github-actions-manager/pkg/utils/channels"
 	"github.com/slack-go/slack"
@@ -42,14 +44,19 @@ func (n *Notifier) Start(ctx context.Context, g *errgroup.Group) error {
 	}
 
 	g.Go(func() error {
-		n.run(ctx)
+		n.run(gh.WithModule(ctx, "slack-notifier"))
 		return nil
 	})
 	return nil
 }
 
+type runStatus struct {
+	attempt int
+	status  string
+}
+
 func (n *Notifier) run(ctx context.Context) {
-	runStatuses := make(map[jobs.Key]string)
+	runStatuses := make(map[jobs.Key]runStatus)
 	sub := channels.NewSubscriber(ctx, n.jobs.State())
 
 	for {
@@ -66,15 +73,16 @@ func (n *Notifier) run(ctx context.Context) {
 			runKeys := make(map[jobs.Key]struct{})
 			for _, run := range s.WorkflowRuns {
 				runKeys[run.Key] = struct{}{}
-				status := runStatuses[run.Key]
-				if run.Status != status {
+				status := runStatus{attempt: run.Attempt, status: run.Status}
+				if runStatuses[run.Key] != status {
 					n.logger.Info("status updated",
 						zap.String("repo", run.RepoName),
+						zap.Int("attempt", run.Attempt),
 						zap.String("status", run.Status),
 						zap.String("conclusion", run.Conclusion),
 					)
 					n.notify(ctx, run)
-					runStatuses[run.Key] = run.Status
+					runStatuses[run.Key] = status
 				}
 			}
 
@@ -115,27 +123,32 @@ func (n *Notifier) notify(ctx context.Context, run *jobs.WorkflowRun) {
 	const colorRed = "#7f1d1d"    // red-900
 	const colorGray = "#94a3b8"   // slate-400
 
+	name := run.Name
+	if run.Attempt > 1 {
+		name = fmt.Sprintf("%s (attempt #%d)", run.Name, run.Attempt)
+	}
+
 	var msg string = ""
 	var color string = colorGray
 	switch run.Conclusion {
 	case "action_required":
-		msg = fmt.Sprintf("%s requires action.", run.Name)
+		msg = fmt.Sprintf("%s requires action.", name)
 		color = colorYellow
 	case "cancelled":
-		msg = fmt.Sprintf("%s is cancelled.", run.Name)
+		msg = fmt.Sprintf("%s is cancelled.", name)
 	case "skipped":
 		msg = ""
 	case "failure":
-		msg = fmt.Sprintf("%s has failed in %s.", run.Name, runtime)
+		msg = fmt.Sprintf("%s has failed in %s.", name, runtime)
 		color = colorRed
 	case "timed_out":
-		msg = fmt.Sprintf("%s timed out in %s.", run.Name, runtime)
+		msg = fmt.Sprintf("%s timed out in %s.", name, runtime)
 		color = colorYellow
 	case "success":
-		msg = fmt.Sprintf("%s has succeeded in %s.", run.Name, runtime)
+		msg = fmt.Sprintf("%s has succeeded in %s.", name, runtime)
 		color = colorGreen
 	default:
-		msg = fmt.Sprintf("%s has completed in %s.", run.Name, runtime)
+		msg = fmt.Sprintf("%s has completed in %s.", name, runtime)
 	}
 
 	if msg == "" {
@@ -157,10 +170,20 @@ func (n *Notifier) notify(ctx context.Context, run *jobs.WorkflowRun) {
 			),
 		}},
 	}
+	if len(run.PreviousAttempts) > 0 {
+		var attempts []string
+		for _, attempt := range run.PreviousAttempts {
+			attempts = append(attempts, fmt.Sprintf("#%d: %s", attempt.Attempt, attempt.Conclusion))
+		}
+		slackMsg.Fields = append(slackMsg.Fields, slack.AttachmentField{
+			Title: "Previous Attempts",
+			Value: slackutilsx.EscapeMessage(strings.Join(attempts, ", ")),
+		})
+	}
 
 	for _, channel := range channels {
 		if len(channel.conclusions) > 0 && !slices.Contains(channel.conclusions, run.Conclusion) {
-			return
+			continue
 		}
 		err := n.app.SendMessage(ctx, channel.channelID, slack.MsgOptionAttachments(slackMsg))
 		if err != nil {
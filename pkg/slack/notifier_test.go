@@ -0,0 +1,142 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/oursky/github-actions-manager/pkg/github/githubtest"
+	"github.com/oursky/github-actions-manager/pkg/github/jobs"
+	"github.com/oursky/github-actions-manager/pkg/kv"
+	"github.com/oursky/github-actions-manager/pkg/utils/channels"
+
+	"github.com/slack-go/slack"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+type fakeJobsState struct {
+	state *channels.Broadcaster[*jobs.State]
+}
+
+func (s *fakeJobsState) State() *channels.Broadcaster[*jobs.State] {
+	return s.state
+}
+
+type sentMessage struct {
+	channel     string
+	attachments []slack.Attachment
+}
+
+// fakeSlackServer records messages posted to Slack API.
+type fakeSlackServer struct {
+	*httptest.Server
+	lock     sync.Mutex
+	messages []sentMessage
+}
+
+func newFakeSlackServer() *fakeSlackServer {
+	s := &fakeSlackServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" {
+			http.NotFound(rw, r)
+			return
+		}
+
+		msg := sentMessage{channel: r.FormValue("channel")}
+		json.Unmarshal([]byte(r.FormValue("attachments")), &msg.attachments)
+		s.lock.Lock()
+		s.messages = append(s.messages, msg)
+		s.lock.Unlock()
+
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]any{"ok": true, "channel": msg.channel, "ts": "1"})
+	}))
+	return s
+}
+
+func (s *fakeSlackServer) Messages() []sentMessage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]sentMessage(nil), s.messages...)
+}
+
+func TestNotifier(t *testing.T) {
+	Convey("Given a Slack notifier", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		server := githubtest.NewServer()
+		defer server.Close()
+		slackServer := newFakeSlackServer()
+		defer slackServer.Close()
+
+		app := &App{
+			logger: zap.NewNop(),
+			api:    slack.New("token", slack.OptionAPIURL(slackServer.URL+"/")),
+			store:  kv.NewInMemoryStore(),
+		}
+		So(app.AddChannel(ctx, "acme/repo", ChannelInfo{channelID: "C-failure", conclusions: []string{"failure"}}), ShouldBeNil)
+		So(app.AddChannel(ctx, "acme/repo", ChannelInfo{channelID: "C-all"}), ShouldBeNil)
+
+		state := &fakeJobsState{state: channels.NewBroadcaster[*jobs.State](nil)}
+		notifier := NewNotifier(zap.NewNop(), app, server.Client(), state)
+
+		g, ctx := errgroup.WithContext(ctx)
+		So(notifier.Start(ctx, g), ShouldBeNil)
+
+		server.SetRunUsage("acme", "repo", 1, &github.WorkflowRunUsage{RunDurationMS: github.Int64(60000)})
+		run := func(attempt int, status string, conclusion string) *jobs.WorkflowRun {
+			return &jobs.WorkflowRun{
+				Key:                jobs.Key{ID: 1, RepoOwner: "acme", RepoName: "repo"},
+				Attempt:            attempt,
+				Name:               "CI",
+				Status:             status,
+				Conclusion:         conclusion,
+				CommitMessageTitle: "Fix build",
+			}
+		}
+
+		Convey("Completed runs are notified to subscribed channels", func() {
+			state.state.Publish(&jobs.State{WorkflowRuns: []*jobs.WorkflowRun{run(1, "in_progress", "")}})
+			state.state.Publish(&jobs.State{WorkflowRuns: []*jobs.WorkflowRun{run(1, "completed", "success")}})
+
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				return len(slackServer.Messages()) == 1
+			})
+			So(ok, ShouldBeTrue)
+
+			msg := slackServer.Messages()[0]
+			So(msg.channel, ShouldEqual, "C-all")
+			So(msg.attachments, ShouldHaveLength, 1)
+			So(msg.attachments[0].Title, ShouldEqual, "CI has succeeded in 1m0s.")
+			So(msg.attachments[0].AuthorName, ShouldEqual, "acme/repo")
+		})
+
+		Convey("Failed attempts are notified with previous attempts", func() {
+			rerun := run(2, "completed", "failure")
+			rerun.PreviousAttempts = []*jobs.WorkflowRun{run(1, "completed", "success")}
+			state.state.Publish(&jobs.State{WorkflowRuns: []*jobs.WorkflowRun{rerun}})
+
+			ok := githubtest.Eventually(5*time.Second, func() bool {
+				return len(slackServer.Messages()) == 2
+			})
+			So(ok, ShouldBeTrue)
+
+			var channelIDs []string
+			for _, msg := range slackServer.Messages() {
+				channelIDs = append(channelIDs, msg.channel)
+				So(msg.attachments[0].Title, ShouldEqual, "CI (attempt #2) has failed in 1m0s.")
+				So(msg.attachments[0].Fields, ShouldHaveLength, 2)
+				So(msg.attachments[0].Fields[1].Value, ShouldEqual, "#1: success")
+			}
+			So(channelIDs, ShouldResemble, []string{"C-failure", "C-all"})
+		})
+	})
+}
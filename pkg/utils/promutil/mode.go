@@ -0,0 +1,31 @@
+package promutil
+
+import (
+	"sort"
+	"strings"
+)
+
+// MetricsMode controls whether per-entity (e.g. per runner or per job)
+// series, or aggregated low-cardinality series are exported.
+type MetricsMode string
+
+const (
+	MetricsModeDetailed   MetricsMode = "Detailed"
+	MetricsModeAggregated MetricsMode = "Aggregated"
+	MetricsModeAll        MetricsMode = "All"
+)
+
+func (m MetricsMode) Detailed() bool {
+	return m == "" || m == MetricsModeDetailed || m == MetricsModeAll
+}
+
+func (m MetricsMode) Aggregated() bool {
+	return m == MetricsModeAggregated || m == MetricsModeAll
+}
+
+// LabelSet returns a stable label value for a set of labels.
+func LabelSet(labels []string) string {
+	sorted := append([]string(nil), labels...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
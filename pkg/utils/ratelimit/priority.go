@@ -0,0 +1,39 @@
+package ratelimit
+
+import "context"
+
+type Priority int
+
+const (
+	// PriorityLow requests are background polling; they yield the reserved
+	// portion of quota to other requests.
+	PriorityLow Priority = -1
+	// PriorityNormal is the priority of requests not tagged with a priority.
+	PriorityNormal Priority = 0
+	// PriorityHigh requests are not queued behind other requests.
+	PriorityHigh Priority = 1
+)
+
+type priorityKey struct{}
+
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+func GetPriority(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityNormal
+}
+
+func (p Priority) String() string {
+	switch {
+	case p <= PriorityLow:
+		return "low"
+	case p >= PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
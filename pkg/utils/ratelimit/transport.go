@@ -1,29 +1,245 @@
 package ratelimit
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/time/rate"
 )
 
+const (
+	defaultReserve      = 0.1
+	secondaryLimitPause = 1 * time.Minute
+	minLimit            = rate.Limit(1.0 / 60)
+)
+
 type Transport struct {
 	Base        http.RoundTripper
 	RateLimiter *rate.Limiter
+	// Reserve is the fraction of quota that low priority requests would not
+	// consume.
+	Reserve float64
+	// OnWait is called with time spent waiting for quota, if set.
+	OnWait func(r *http.Request, d time.Duration)
+
+	maxLimit   rate.Limit
+	lock       *sync.Mutex
+	limit      int
+	remaining  int
+	resetAt    time.Time
+	pauseUntil time.Time
 }
 
 func NewTransport(base http.RoundTripper, limit rate.Limit, brust int) *Transport {
 	return &Transport{
 		Base:        base,
 		RateLimiter: rate.NewLimiter(limit, brust),
+		Reserve:     defaultReserve,
+		maxLimit:    limit,
+		lock:        new(sync.Mutex),
 	}
 }
 
 func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
-	if err := t.RateLimiter.Wait(r.Context()); err != nil {
+	start := time.Now()
+	err := t.wait(r.Context(), GetPriority(r.Context()))
+	if t.OnWait != nil {
+		t.OnWait(r, time.Since(start))
+	}
+	if err != nil {
 		if r.Body != nil {
 			r.Body.Close()
 		}
 		return nil, err
 	}
-	return t.Base.RoundTrip(r)
+
+	resp, err := t.Base.RoundTrip(r)
+	if err != nil {
+		return resp, err
+	}
+
+	t.update(resp)
+	return resp, nil
+}
+
+// Headroom returns the fraction of quota remaining, 1 if unknown, or 0 if
+// requests are paused.
+func (t *Transport) Headroom() float64 {
+	now := time.Now()
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if now.Before(t.pauseUntil) {
+		return 0
+	}
+	if t.limit <= 0 || !now.Before(t.resetAt) {
+		return 1
+	}
+	return float64(t.remaining) / float64(t.limit)
+}
+
+func (t *Transport) wait(ctx context.Context, priority Priority) error {
+	for {
+		now := time.Now()
+		until := t.blockedUntil(priority, now)
+		if !until.After(now) {
+			break
+		}
+
+		timer := time.NewTimer(until.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if priority >= PriorityHigh {
+		// Go ahead of queued requests, while still consuming quota.
+		t.RateLimiter.Reserve()
+		return nil
+	}
+	return t.RateLimiter.Wait(ctx)
+}
+
+func (t *Transport) blockedUntil(priority Priority, now time.Time) time.Time {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if !t.resetAt.IsZero() && !now.Before(t.resetAt) {
+		// Quota is reset, restore to configured pace.
+		t.limit = 0
+		t.remaining = 0
+		t.resetAt = time.Time{}
+		t.RateLimiter.SetLimitAt(now, t.maxLimit)
+	}
+
+	until := t.pauseUntil
+	if t.limit > 0 {
+		reserved := 0
+		if priority <= PriorityLow {
+			reserved = int(float64(t.limit) * t.Reserve)
+		}
+		if t.remaining <= reserved && t.resetAt.After(until) {
+			until = t.resetAt
+		}
+	}
+	return until
+}
+
+func (t *Transport) update(resp *http.Response) {
+	now := time.Now()
+	limit, remaining, resetAt, hasQuota := parseQuota(resp.Header)
+
+	isSecondaryLimit := false
+	if resp.StatusCode == http.StatusForbidden {
+		isSecondaryLimit = isSecondaryLimitResponse(resp)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if hasQuota {
+		t.limit = limit
+		t.remaining = remaining
+		t.resetAt = resetAt
+		t.adjustLimit(now)
+	}
+
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return
+	}
+
+	var pauseUntil time.Time
+	if retryAfter, ok := parseRetryAfter(resp.Header); ok {
+		pauseUntil = now.Add(retryAfter)
+	} else if hasQuota && remaining == 0 {
+		pauseUntil = resetAt
+	} else if resp.StatusCode == http.StatusTooManyRequests || isSecondaryLimit {
+		pauseUntil = now.Add(secondaryLimitPause)
+	}
+	if pauseUntil.After(t.pauseUntil) {
+		t.pauseUntil = pauseUntil
+	}
+}
+
+// adjustLimit paces requests such that remaining quota lasts until reset.
+func (t *Transport) adjustLimit(now time.Time) {
+	window := t.resetAt.Sub(now)
+	if window <= 0 {
+		t.RateLimiter.SetLimitAt(now, t.maxLimit)
+		return
+	}
+
+	limit := rate.Limit(float64(t.remaining) / window.Seconds())
+	if limit > t.maxLimit {
+		limit = t.maxLimit
+	}
+	if limit < minLimit {
+		limit = minLimit
+	}
+	t.RateLimiter.SetLimitAt(now, limit)
+}
+
+func parseQuota(header http.Header) (limit int, remaining int, resetAt time.Time, ok bool) {
+	if res := header.Get("X-RateLimit-Resource"); res != "" && res != "core" {
+		return 0, 0, time.Time{}, false
+	}
+
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return 0, 0, time.Time{}, false
+	}
+	remaining, err = strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return 0, 0, time.Time{}, false
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, 0, time.Time{}, false
+	}
+	return limit, remaining, time.Unix(reset, 0), true
+}
+
+func parseRetryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+func isSecondaryLimitResponse(resp *http.Response) bool {
+	if resp.Body == nil {
+		return false
+	}
+
+	// Error responses are small; peek the message and restore the body.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	resp.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(data), resp.Body),
+		Closer: resp.Body,
+	}
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(data)), "secondary rate limit")
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
 }
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/time/rate"
+)
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func newResponse(status int, header http.Header, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func quotaHeader(limit int, remaining int, resetAt time.Time) http.Header {
+	h := http.Header{}
+	h.Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	h.Set("X-RateLimit-Reset", strconv.FormatInt(resetAt.Unix(), 10))
+	return h
+}
+
+func TestTransport(t *testing.T) {
+	Convey("Given a rate limited transport", t, func() {
+		var next *http.Response
+		calls := 0
+		base := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			calls++
+			return next, nil
+		})
+		transport := NewTransport(base, rate.Inf, 10)
+
+		do := func(ctx context.Context) error {
+			r, _ := http.NewRequestWithContext(ctx, "GET", "https://api.github.com/", nil)
+			_, err := transport.RoundTrip(r)
+			return err
+		}
+		shortCtx := func(priority Priority) (context.Context, func()) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			return WithPriority(ctx, priority), cancel
+		}
+
+		Convey("Low priority requests yield reserved quota", func() {
+			next = newResponse(200, quotaHeader(5000, 100, time.Now().Add(time.Hour)), "")
+			So(do(context.Background()), ShouldBeNil)
+
+			ctx, cancel := shortCtx(PriorityLow)
+			defer cancel()
+			So(do(ctx), ShouldEqual, context.DeadlineExceeded)
+
+			ctx, cancel = shortCtx(PriorityHigh)
+			defer cancel()
+			So(do(ctx), ShouldBeNil)
+			So(calls, ShouldEqual, 2)
+		})
+
+		Convey("Requests are paused after secondary rate limit", func() {
+			header := quotaHeader(5000, 4000, time.Now().Add(time.Hour))
+			header.Set("Retry-After", "30")
+			next = newResponse(403, header, `{"message":"You have exceeded a secondary rate limit."}`)
+			So(do(context.Background()), ShouldBeNil)
+
+			ctx, cancel := shortCtx(PriorityHigh)
+			defer cancel()
+			So(do(ctx), ShouldEqual, context.DeadlineExceeded)
+			So(calls, ShouldEqual, 1)
+		})
+
+		Convey("Forbidden responses without rate limit are not paused", func() {
+			next = newResponse(403, quotaHeader(5000, 4000, time.Now().Add(time.Hour)), `{"message":"Resource not accessible"}`)
+			So(do(context.Background()), ShouldBeNil)
+			So(do(context.Background()), ShouldBeNil)
+			So(calls, ShouldEqual, 2)
+		})
+	})
+}